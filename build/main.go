@@ -17,11 +17,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/cloudfoundry/build-system-cnb/buildsystem"
 	"github.com/cloudfoundry/build-system-cnb/cache"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/build-system-cnb/runner"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 )
@@ -44,6 +46,12 @@ func main() {
 func b(build build.Build) (int, error) {
 	build.Logger.Title(build.Buildpack)
 
+	if c, err := config.NewConfiguration(build.Application); err != nil {
+		return build.Failure(102), err
+	} else if err := c.Configure(build.Logger); err != nil {
+		return build.Failure(102), err
+	}
+
 	if b, ok, err := buildsystem.NewGradleBuildSystem(build); err != nil {
 		return build.Failure(102), err
 	} else if ok {
@@ -63,7 +71,7 @@ func b(build build.Build) (int, error) {
 			return build.Failure(102), err
 		} else {
 			if err = runner.Contribute(); err != nil {
-				return build.Failure(103), err
+				return build.Failure(runnerFailureCode(err)), err
 			}
 		}
 	}
@@ -87,10 +95,18 @@ func b(build build.Build) (int, error) {
 			return build.Failure(102), err
 		} else {
 			if err = runner.Contribute(); err != nil {
-				return build.Failure(103), err
+				return build.Failure(runnerFailureCode(err)), err
 			}
 		}
 	}
 
 	return build.Success()
 }
+
+func runnerFailureCode(err error) int {
+	if errors.As(err, &runner.TestFailureError{}) {
+		return runner.TestFailureCode
+	}
+
+	return 103
+}
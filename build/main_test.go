@@ -17,8 +17,10 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/cloudfoundry/build-system-cnb/runner"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 	"github.com/cloudfoundry/libcfbuildpack/v2/test"
 	"github.com/onsi/gomega"
@@ -36,5 +38,10 @@ func TestBuild(t *testing.T) {
 
 			g.Expect(b(f.Build)).To(gomega.Equal(build.SuccessStatusCode))
 		})
+
+		it("uses dedicated exit code for test failures", func() {
+			g.Expect(runnerFailureCode(runner.TestFailureError{})).To(gomega.Equal(runner.TestFailureCode))
+			g.Expect(runnerFailureCode(fmt.Errorf("test-error"))).To(gomega.Equal(103))
+		})
 	}, spec.Report(report.Terminal{}))
 }
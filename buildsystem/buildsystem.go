@@ -17,6 +17,8 @@
 package buildsystem
 
 import (
+	"path/filepath"
+
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
 	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
@@ -24,16 +26,27 @@ import (
 
 // BuildSystem represents the build system distribution contributed by the buildpack.
 type BuildSystem struct {
-	contributor  layers.DependencyLayerContributor
-	distribution string
-	layer        layers.DependencyLayer
-	logger       logger.Logger
-	wrapper      string
+	contributor       layers.DependencyLayerContributor
+	distribution      string
+	layer             layers.DependencyLayer
+	logger            logger.Logger
+	offline           bool
+	wrapper           string
+	wrapperHome       string
+	wrapperLayout     wrapperLayout
+	wrapperProperties string
 }
 
-// Contribute makes the contribution to the cache layer.
+// Contribute makes the contribution to the cache layer.  If the build is offline, a wrapper is only used if its
+// distribution has already been downloaded.
 func (b BuildSystem) Contribute() error {
 	if b.hasWrapper() {
+		if b.offline {
+			if err := b.verifyWrapperDistribution(); err != nil {
+				return err
+			}
+		}
+
 		b.logger.Body("Using wrapper")
 		return nil
 	}
@@ -51,6 +64,31 @@ func (b BuildSystem) Executable() string {
 	return b.distribution
 }
 
+// Version returns the version of the build system that should be used.  Will be the distribution URL of the wrapper
+// if it exists, the version of the contributed build system distribution otherwise.  Empty if the version is unknown.
+func (b BuildSystem) Version() (string, error) {
+	if b.hasWrapper() {
+		if exists, err := helper.FileExists(b.wrapperProperties); err != nil || !exists {
+			return "", nil
+		}
+
+		distributionURL, _, err := wrapperDistribution(b.wrapperProperties, b.wrapperLayout, b.wrapperHome,
+			filepath.Dir(b.wrapper))
+		return distributionURL, err
+	}
+
+	if b.layer.Dependency.Version.Version == nil {
+		return "", nil
+	}
+
+	return b.layer.Dependency.Version.Original(), nil
+}
+
+// Offline returns whether the build system must run without network access.
+func (b BuildSystem) Offline() bool {
+	return b.offline
+}
+
 func (b BuildSystem) hasWrapper() bool {
 	exists, err := helper.FileExists(b.wrapper)
 	if err != nil {
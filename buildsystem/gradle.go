@@ -17,10 +17,13 @@
 package buildsystem
 
 import (
+	"os"
+	"os/user"
 	"path/filepath"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
 	"github.com/buildpacks/libbuildpack/v2/buildplan"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
@@ -73,11 +76,31 @@ func NewGradleBuildSystem(build build.Build) (BuildSystem, bool, error) {
 		return BuildSystem{}, false, err
 	}
 
-	dep, err := deps.Best(GradleDependency, p.Version, build.Stack)
+	version := p.Version
+	if v, ok := config.Lookup("BP_GRADLE_VERSION"); ok {
+		version = v
+	}
+
+	dep, err := deps.Best(GradleDependency, version, build.Stack)
 	if err != nil {
 		return BuildSystem{}, false, err
 	}
 
+	offline, err := IsOffline()
+	if err != nil {
+		return BuildSystem{}, false, err
+	}
+
+	wrapperHome, ok := os.LookupEnv("GRADLE_USER_HOME")
+	if !ok {
+		u, err := user.Current()
+		if err != nil {
+			return BuildSystem{}, false, err
+		}
+
+		wrapperHome = filepath.Join(u.HomeDir, ".gradle")
+	}
+
 	layer := build.Layers.DependencyLayer(dep)
 	distribution := filepath.Join(layer.Root, "bin", "gradle")
 	wrapper := filepath.Join(build.Application.Root, "gradlew")
@@ -87,7 +110,11 @@ func NewGradleBuildSystem(build build.Build) (BuildSystem, bool, error) {
 		distribution,
 		layer,
 		build.Logger,
+		offline,
 		wrapper,
+		wrapperHome,
+		gradleWrapperLayout,
+		filepath.Join(build.Application.Root, "gradle", "wrapper", "gradle-wrapper.properties"),
 	}, true, nil
 }
 
@@ -17,6 +17,7 @@
 package buildsystem_test
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -85,6 +86,96 @@ func TestGradle(t *testing.T) {
 			})
 		})
 
+		when("Version", func() {
+
+			it.Before(func() {
+				f.AddDependency(buildsystem.GradleDependency, filepath.Join("testdata", "stub-gradle.zip"))
+				f.AddPlan(buildpackplan.Plan{Name: buildsystem.GradleDependency})
+			})
+
+			it("returns distribution version if gradlew does not exist", func() {
+				b, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Version()).To(gomega.Equal("1.0"))
+			})
+
+			it("returns wrapper distribution URL if gradlew does exist", func() {
+				test.TouchFile(t, f.Build.Application.Root, "gradlew")
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "gradle", "wrapper", "gradle-wrapper.properties"),
+					`distributionUrl=https\://services.gradle.org/distributions/gradle-6.2.2-bin.zip`)
+
+				b, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Version()).To(gomega.Equal("https://services.gradle.org/distributions/gradle-6.2.2-bin.zip"))
+			})
+		})
+
+		when("offline", func() {
+
+			var home string
+
+			it.Before(func() {
+				home = filepath.Join(f.Home, ".gradle")
+
+				f.AddDependency(buildsystem.GradleDependency, filepath.Join("testdata", "stub-gradle.zip"))
+				f.AddPlan(buildpackplan.Plan{Name: buildsystem.GradleDependency})
+
+				test.TouchFile(t, f.Build.Application.Root, "gradlew")
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "gradle", "wrapper", "gradle-wrapper.properties"),
+					`distributionUrl=https\://services.gradle.org/distributions/gradle-6.2.2-bin.zip`)
+			})
+
+			it("uses wrapper if distribution is cached", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "GRADLE_USER_HOME", home)()
+				test.TouchFile(t, home, "wrapper", "dists", "gradle-6.2.2-bin", "byfcpklxknejjsdmgq0rkga", "gradle-6.2.2-bin.zip.ok")
+
+				b, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Offline()).To(gomega.BeTrue())
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+			})
+
+			it("uses wrapper if distribution is cached in project", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "GRADLE_USER_HOME", home)()
+				test.WriteFile(t,
+					filepath.Join(f.Build.Application.Root, "gradle", "wrapper", "gradle-wrapper.properties"),
+					`distributionBase=PROJECT
+distributionPath=wrapper/dists
+distributionUrl=https\://services.gradle.org/distributions/gradle-6.2.2-bin.zip`)
+				test.TouchFile(t, f.Build.Application.Root, "wrapper", "dists", "gradle-6.2.2-bin", "byfcpklxknejjsdmgq0rkga",
+					"gradle-6.2.2-bin.zip.ok")
+
+				b, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+			})
+
+			it("fails if wrapper distribution is not cached", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "GRADLE_USER_HOME", home)()
+
+				b, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.MatchError(fmt.Sprintf(
+					"offline build requires wrapper distribution https://services.gradle.org/distributions/gradle-6.2.2-bin.zip to be cached in %s",
+					filepath.Join(home, "wrapper", "dists", "gradle-6.2.2-bin", "byfcpklxknejjsdmgq0rkga"))))
+			})
+
+			it("fails with invalid $BP_OFFLINE", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "test-value")()
+
+				_, _, err := buildsystem.NewGradleBuildSystem(f.Build)
+				g.Expect(err).To(gomega.MatchError("invalid $BP_OFFLINE: test-value is not a boolean"))
+			})
+		})
+
 		when("IsGradle", func() {
 
 			it("returns false if build.gradle does not exist", func() {
@@ -17,10 +17,13 @@
 package buildsystem
 
 import (
+	"os"
+	"os/user"
 	"path/filepath"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
 	"github.com/buildpacks/libbuildpack/v2/buildplan"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
@@ -68,11 +71,31 @@ func NewMavenBuildSystem(build build.Build) (BuildSystem, bool, error) {
 		return BuildSystem{}, false, err
 	}
 
-	dep, err := deps.Best(MavenDependency, p.Version, build.Stack)
+	version := p.Version
+	if v, ok := config.Lookup("BP_MAVEN_VERSION"); ok {
+		version = v
+	}
+
+	dep, err := deps.Best(MavenDependency, version, build.Stack)
 	if err != nil {
 		return BuildSystem{}, false, err
 	}
 
+	offline, err := IsOffline()
+	if err != nil {
+		return BuildSystem{}, false, err
+	}
+
+	wrapperHome, ok := os.LookupEnv("MAVEN_USER_HOME")
+	if !ok {
+		u, err := user.Current()
+		if err != nil {
+			return BuildSystem{}, false, err
+		}
+
+		wrapperHome = filepath.Join(u.HomeDir, ".m2")
+	}
+
 	layer := build.Layers.DependencyLayer(dep)
 	distribution := filepath.Join(layer.Root, "bin", "mvn")
 	wrapper := filepath.Join(build.Application.Root, "mvnw")
@@ -82,7 +105,11 @@ func NewMavenBuildSystem(build build.Build) (BuildSystem, bool, error) {
 		distribution,
 		layer,
 		build.Logger,
+		offline,
 		wrapper,
+		wrapperHome,
+		mavenWrapperLayout,
+		filepath.Join(build.Application.Root, ".mvn", "wrapper", "maven-wrapper.properties"),
 	}, true, nil
 }
 
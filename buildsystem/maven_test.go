@@ -17,6 +17,7 @@
 package buildsystem_test
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -85,6 +86,59 @@ func TestMaven(t *testing.T) {
 			})
 		})
 
+		when("offline", func() {
+
+			var home string
+
+			it.Before(func() {
+				home = filepath.Join(f.Home, ".m2")
+
+				f.AddDependency(buildsystem.MavenDependency, filepath.Join("testdata", "stub-maven.tar.gz"))
+				f.AddPlan(buildpackplan.Plan{Name: buildsystem.MavenDependency})
+
+				test.TouchFile(t, f.Build.Application.Root, "mvnw")
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, ".mvn", "wrapper", "maven-wrapper.properties"),
+					"distributionUrl=https\\://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.9.6/"+
+						"apache-maven-3.9.6-bin.zip")
+			})
+
+			it("uses wrapper if distribution is cached", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "MAVEN_USER_HOME", home)()
+				test.TouchFile(t, home, "wrapper", "dists", "apache-maven-3.9.6", "a53741d1", "bin", "mvn")
+
+				b, _, err := buildsystem.NewMavenBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Offline()).To(gomega.BeTrue())
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+			})
+
+			it("uses wrapper if distribution is cached by maven-wrapper before 3.3", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "MAVEN_USER_HOME", home)()
+				test.TouchFile(t, home, "wrapper", "dists", "apache-maven-3.9.6-bin", "7ruux7xj2f57pxcaizx9qfde6",
+					"apache-maven-3.9.6-bin.zip.ok")
+
+				b, _, err := buildsystem.NewMavenBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+			})
+
+			it("fails if wrapper distribution is not cached", func() {
+				defer test.ReplaceEnv(t, "BP_OFFLINE", "true")()
+				defer test.ReplaceEnv(t, "MAVEN_USER_HOME", home)()
+
+				b, _, err := buildsystem.NewMavenBuildSystem(f.Build)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.MatchError(fmt.Sprintf("offline build requires wrapper distribution "+
+					"https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.9.6/apache-maven-3.9.6-bin.zip "+
+					"to be cached in %s", filepath.Join(home, "wrapper", "dists", "apache-maven-3.9.6", "a53741d1"))))
+			})
+		})
+
 		when("IsMaven", func() {
 
 			it("returns false if pom.xml does not exist", func() {
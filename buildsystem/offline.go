@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package buildsystem
+
+import (
+	"crypto/md5"
+	"fmt"
+	"math/big"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/magiconair/properties"
+)
+
+// IsOffline returns whether $BP_OFFLINE requires the build to run without network access.
+func IsOffline() (bool, error) {
+	return config.Bool("BP_OFFLINE")
+}
+
+// wrapperLayout returns the directories, in order of preference, that a wrapper may expand the distribution at
+// distributionURL to.  home is the wrapper's user home and project the directory containing the wrapper.
+type wrapperLayout func(p *properties.Properties, distributionURL string, home string, project string) []string
+
+// wrapperDistribution returns the url of the distribution described by a wrapper properties file and the directories
+// that the wrapper may expand it to.
+func wrapperDistribution(file string, layout wrapperLayout, home string, project string) (string, []string, error) {
+	p, err := properties.LoadFile(file, properties.UTF8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	distributionURL, ok := p.Get("distributionUrl")
+	if !ok {
+		return "", nil, fmt.Errorf("%s does not contain distributionUrl", file)
+	}
+
+	if _, err := url.Parse(distributionURL); err != nil {
+		return "", nil, fmt.Errorf("invalid distributionUrl in %s", file)
+	}
+
+	return distributionURL, layout(p, distributionURL, home, project), nil
+}
+
+// gradleWrapperLayout derives the directory in the same way as the wrapper's PathAssembler: the distribution name
+// without extension and the base-36 MD5 of the url, beneath distributionPath relative to home, or to project if
+// distributionBase is PROJECT.
+func gradleWrapperLayout(p *properties.Properties, distributionURL string, home string, project string) []string {
+	hash := md5.Sum([]byte(distributionURL))
+	id := new(big.Int).SetBytes(hash[:]).Text(36)
+
+	base := home
+	if p.GetString("distributionBase", "") == "PROJECT" {
+		base = project
+	}
+	distributionPath := p.GetString("distributionPath", filepath.Join("wrapper", "dists"))
+
+	return []string{filepath.Join(base, distributionPath, distributionName(distributionURL), id)}
+}
+
+// mavenWrapperLayout derives the directory in the same way as maven-wrapper 3.3: the distribution name without
+// extension or -bin suffix and the hexadecimal Java String.hashCode of the url, beneath wrapper/dists in home.  Earlier
+// versions of maven-wrapper use the Gradle wrapper's layout, which is accepted as well.
+func mavenWrapperLayout(p *properties.Properties, distributionURL string, home string, project string) []string {
+	var hash uint32
+	for _, c := range utf16.Encode([]rune(distributionURL)) {
+		hash = hash*31 + uint32(c)
+	}
+
+	name := strings.TrimSuffix(distributionName(distributionURL), "-bin")
+
+	return append([]string{filepath.Join(home, "wrapper", "dists", name, strconv.FormatUint(uint64(hash), 16))},
+		gradleWrapperLayout(p, distributionURL, home, project)...)
+}
+
+// distributionName returns the file name, without extension, of the distribution at distributionURL.
+func distributionName(distributionURL string) string {
+	u, _ := url.Parse(distributionURL)
+	name := path.Base(u.Path)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func (b BuildSystem) verifyWrapperDistribution() error {
+	distributionURL, distributions, err := wrapperDistribution(b.wrapperProperties, b.wrapperLayout, b.wrapperHome,
+		filepath.Dir(b.wrapper))
+	if err != nil {
+		return err
+	}
+
+	for _, d := range distributions {
+		if exists, err := helper.FileExists(d); err != nil {
+			return err
+		} else if exists {
+			b.logger.Debug("Wrapper distribution %s cached in %s", distributionURL, d)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("offline build requires wrapper distribution %s to be cached in %s", distributionURL,
+		distributions[0])
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/mattn/go-shellwords"
+)
+
+// Configuration is the build system configuration, composed of the environment and the [metadata.build-system] table
+// of project.toml.  Environment variables take precedence over project.toml.
+type Configuration struct {
+	// Project is the configuration in project.toml, keyed by environment variable name.
+	Project map[string]string
+}
+
+type descriptor struct {
+	Metadata struct {
+		BuildSystem map[string]interface{} `toml:"build-system"`
+	} `toml:"metadata"`
+}
+
+// Configure validates the configuration, sets the environment variables configured in project.toml in the current
+// process unless they are already set, warns about unrecognized environment variables that are close to a supported
+// key, and logs the effective configuration.
+func (c Configuration) Configure(logger logger.Logger) error {
+	for _, s := range unrecognized(os.Environ()) {
+		logger.BodyWarning(s)
+	}
+
+	var effective []string
+	for _, k := range Keys {
+		if v, ok := os.LookupEnv(k.Name); ok {
+			effective = append(effective, fmt.Sprintf("$%s=%s (environment)", k.Name, display(k, v)))
+		} else if v, ok := c.Project[k.Name]; ok {
+			if err := os.Setenv(k.Name, v); err != nil {
+				return err
+			}
+
+			effective = append(effective, fmt.Sprintf("$%s=%s (project.toml)", k.Name, display(k, v)))
+		} else if k.Default != "" {
+			effective = append(effective, fmt.Sprintf("$%s=%s (default)", k.Name, k.Default))
+		}
+
+		if err := validate(k); err != nil {
+			return err
+		}
+	}
+
+	logger.Header("Build configuration")
+	for _, s := range effective {
+		logger.Body(s)
+	}
+
+	return nil
+}
+
+// NewConfiguration creates a new Configuration instance, reading <APPLICATION_ROOT>/project.toml if it exists.
+func NewConfiguration(application application.Application) (Configuration, error) {
+	c := Configuration{Project: make(map[string]string)}
+
+	file := filepath.Join(application.Root, "project.toml")
+
+	if exists, err := helper.FileExists(file); err != nil {
+		return Configuration{}, err
+	} else if !exists {
+		return c, nil
+	}
+
+	var d descriptor
+	if _, err := toml.DecodeFile(file, &d); err != nil {
+		return Configuration{}, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+
+	for name, value := range d.Metadata.BuildSystem {
+		k, ok := project(name)
+		if !ok {
+			return Configuration{}, fmt.Errorf("unable to parse %s: unknown key %s in [metadata.build-system]", file, name)
+		}
+
+		switch v := value.(type) {
+		case bool:
+			if k.Type != Boolean {
+				return Configuration{}, fmt.Errorf("unable to parse %s: %s must be a %s", file, name, k.Type)
+			}
+			c.Project[k.Name] = strconv.FormatBool(v)
+		case string:
+			if k.Type == Boolean {
+				return Configuration{}, fmt.Errorf("unable to parse %s: %s must be a %s", file, name, k.Type)
+			}
+			c.Project[k.Name] = v
+		default:
+			return Configuration{}, fmt.Errorf("unable to parse %s: %s must be a %s", file, name, k.Type)
+		}
+	}
+
+	return c, nil
+}
+
+func display(k Key, value string) string {
+	switch k.Type {
+	case Arguments, KeyValues:
+		w, err := shellwords.Parse(value)
+		if err != nil {
+			return "xxxxx"
+		}
+
+		w = RedactArguments(w)
+		for i, a := range w {
+			if strings.ContainsAny(a, " \t\n'\"") {
+				w[i] = strconv.Quote(a)
+			}
+		}
+
+		return strings.Join(w, " ")
+	case URL:
+		return RedactURL(value)
+	default:
+		return value
+	}
+}
+
+func project(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Project != "" && k.Project == name {
+			return k, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// unrecognized returns warnings for BP_* environment variables that are not declared but are close to a declared key,
+// which are most likely typos.  Variables that are not close to any key are assumed to belong to other buildpacks.
+func unrecognized(environment []string) []string {
+	var warnings []string
+
+	for _, e := range environment {
+		name := strings.SplitN(e, "=", 2)[0]
+		if !strings.HasPrefix(name, "BP_") || declared(name) {
+			continue
+		}
+
+		for _, k := range Keys {
+			if distance(name, k.Name) <= 2 {
+				warnings = append(warnings, fmt.Sprintf("Ignoring unrecognized $%s, did you mean $%s?", name, k.Name))
+				break
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+func declared(name string) bool {
+	for _, k := range Keys {
+		if k.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// distance returns the Levenshtein distance between two strings.
+func distance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// Type is the type of the value of a configuration key.
+type Type string
+
+const (
+	// Arguments is a list of arguments parsed with shell quoting rules.
+	Arguments Type = "arguments"
+
+	// Boolean is a boolean parsed with strconv.ParseBool.
+	Boolean Type = "boolean"
+
+	// KeyValues is a list of key=value pairs parsed with shell quoting rules.
+	KeyValues Type = "key=value pairs"
+
+	// List is a comma or whitespace separated list.
+	List Type = "list"
+
+	// String is an arbitrary string.
+	String Type = "string"
+
+	// URL is an absolute URL.
+	URL Type = "URL"
+)
+
+// Key is the declaration of a supported configuration key.
+type Key struct {
+	// Name is the name of the environment variable.
+	Name string
+
+	// Type is the type of the value.
+	Type Type
+
+	// Default is the value used when the key is not configured.
+	Default string
+
+	// Description is a human-readable description of the key.
+	Description string
+
+	// Project is the name of the key in the [metadata.build-system] table of project.toml, if it can be set there.
+	Project string
+}
+
+// Keys are all of the supported configuration keys.
+var Keys = []Key{
+	{Name: "BP_BUILD_ARGUMENTS", Type: Arguments, Project: "arguments",
+		Description: "arguments that replace the default build arguments"},
+	{Name: "BP_BUILD_ARGUMENTS_APPEND", Type: Arguments,
+		Description: "arguments appended to the build arguments"},
+	{Name: "BP_BUILT_ARTIFACT", Type: List, Project: "built-artifact",
+		Description: "glob patterns of the built artifact, relative to the application root"},
+	{Name: "BP_BUILT_ARTIFACT_EXCLUDE", Type: List, Project: "built-artifact-exclude",
+		Description: "patterns of candidate built artifacts that are not the application"},
+	{Name: "BP_BUILT_MODULE", Type: String, Project: "built-module",
+		Description: "module containing the built artifact"},
+	{Name: "BP_GRADLE_BUILD_ARGUMENTS", Type: Arguments,
+		Description: "arguments that replace the default Gradle build arguments, taking precedence over $BP_BUILD_ARGUMENTS"},
+	{Name: "BP_GRADLE_MIRROR_URL", Type: URL,
+		Description: "mirror that all Gradle repositories are rewritten to"},
+	{Name: "BP_GRADLE_PROPERTIES", Type: KeyValues,
+		Description: "project properties passed to Gradle"},
+	{Name: "BP_GRADLE_TASKS", Type: Arguments, Default: "build",
+		Description: "tasks run by Gradle"},
+	{Name: "BP_GRADLE_VERSION", Type: String, Project: "gradle-version",
+		Description: "version of Gradle used when a wrapper does not exist"},
+	{Name: "BP_INCREMENTAL", Type: Boolean, Default: "false", Project: "incremental",
+		Description: "whether build output directories are kept between builds for incremental compilation"},
+	{Name: "BP_MAVEN_BUILD_ARGUMENTS", Type: Arguments,
+		Description: "arguments that replace the default Maven build arguments, taking precedence over $BP_BUILD_ARGUMENTS"},
+	{Name: "BP_MAVEN_GOALS", Type: Arguments, Default: "package",
+		Description: "goals run by Maven"},
+	{Name: "BP_MAVEN_MIRROR_URL", Type: URL,
+		Description: "mirror of all Maven repositories"},
+	{Name: "BP_MAVEN_PROFILES", Type: List,
+		Description: "profiles activated by Maven"},
+	{Name: "BP_MAVEN_PROPERTIES", Type: KeyValues,
+		Description: "system properties passed to Maven"},
+	{Name: "BP_MAVEN_VERSION", Type: String, Project: "maven-version",
+		Description: "version of Maven used when a wrapper does not exist"},
+	{Name: "BP_OFFLINE", Type: Boolean, Default: "false",
+		Description: "whether the build runs without network access"},
+	{Name: "BP_RUN_TESTS", Type: Boolean, Default: "false", Project: "run-tests",
+		Description: "whether tests are run during the build"},
+	{Name: "BP_SOURCE_EXCLUDE", Type: List, Project: "source-exclude",
+		Description: ".gitignore-style patterns of files excluded from the source fingerprint"},
+}
+
+// Get returns the declaration of a configuration key.  Panics if the key is not declared, as reading an undeclared key
+// is a programming error.
+func Get(name string) Key {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k
+		}
+	}
+
+	panic(fmt.Sprintf("configuration key %s is not declared", name))
+}
+
+// Lookup returns the configured value of a key and whether it is configured.
+func Lookup(name string) (string, bool) {
+	Get(name)
+	return os.LookupEnv(name)
+}
+
+// Bool returns the value of a Boolean key, or its default if it is not configured.
+func Bool(name string) (bool, error) {
+	s, ok := Lookup(name)
+	if !ok {
+		s = Get(name).Default
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid $%s: %s is not a boolean", name, s)
+	}
+
+	return b, nil
+}
+
+// Words returns the value of an Arguments or KeyValues key and whether it is configured.  If it is not configured, the
+// default, if any, is returned.
+func Words(name string) ([]string, bool, error) {
+	s, ok := Lookup(name)
+	if !ok {
+		s = Get(name).Default
+	}
+
+	w, err := shellwords.Parse(s)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid $%s: %w", name, err)
+	}
+
+	if Get(name).Type == KeyValues {
+		for _, p := range w {
+			if i := strings.Index(p, "="); i < 1 {
+				return nil, false, fmt.Errorf("invalid $%s: %s is not of the form key=value", name, p)
+			}
+		}
+	}
+
+	return w, ok, nil
+}
+
+// Values returns the value of a List key.
+func Values(name string) []string {
+	s, _ := Lookup(name)
+
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// AbsoluteURL returns the value of a URL key and whether it is configured.
+func AbsoluteURL(name string) (string, bool, error) {
+	s, ok := Lookup(name)
+	if !ok {
+		return "", false, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid $%s: unable to parse URL", name)
+	}
+
+	if u.Scheme == "" {
+		return "", false, fmt.Errorf("invalid $%s: %s is not an absolute URL", name, RedactURL(s))
+	}
+
+	return s, true, nil
+}
+
+// sensitive are the fragments of argument and property names whose values are redacted.
+var sensitive = []string{"credential", "key", "passwd", "password", "secret", "token"}
+
+// RedactArguments returns the arguments with the values of those whose names look like credentials, such as
+// -Dpassword=value, --password value, or token=value, and the passwords of URLs, replaced.
+func RedactArguments(arguments []string) []string {
+	var redacted []string
+
+	for i := 0; i < len(arguments); i++ {
+		a := arguments[i]
+
+		if j := strings.Index(a, "="); j > 0 {
+			if isSensitive(a[:j]) {
+				redacted = append(redacted, a[:j+1]+"xxxxx")
+			} else {
+				redacted = append(redacted, a[:j+1]+RedactURL(a[j+1:]))
+			}
+			continue
+		}
+
+		redacted = append(redacted, RedactURL(a))
+
+		if strings.HasPrefix(a, "-") && isSensitive(a) && i+1 < len(arguments) && !strings.HasPrefix(arguments[i+1], "-") {
+			redacted = append(redacted, "xxxxx")
+			i++
+		}
+	}
+
+	return redacted
+}
+
+// RedactURL returns the URL with the password, if any, replaced.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+
+	return u.String()
+}
+
+func validate(k Key) error {
+	var err error
+
+	switch k.Type {
+	case Arguments, KeyValues:
+		_, _, err = Words(k.Name)
+	case Boolean:
+		_, err = Bool(k.Name)
+	case URL:
+		_, _, err = AbsoluteURL(k.Name)
+	}
+
+	return err
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, s := range sensitive {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,6 +3,7 @@ module github.com/cloudfoundry/build-system-cnb
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/buildpacks/libbuildpack/v2 v2.0.7
 	github.com/cloudfoundry/libcfbuildpack/v2 v2.1.8
 	github.com/magiconair/properties v1.8.1
@@ -17,25 +17,180 @@
 package runner
 
 import (
-	"os"
+	"fmt"
+	"path/filepath"
+	"strings"
 
-	"github.com/mattn/go-shellwords"
+	"github.com/cloudfoundry/build-system-cnb/config"
 )
 
+// BuildArgumentsProvider provides the arguments passed to a build system.
 type BuildArgumentsProvider struct {
 	Arguments []string
 }
 
-func NewBuildArgumentsProvider(defaultArguments ...string) (BuildArgumentsProvider, error) {
-	p := BuildArgumentsProvider{defaultArguments}
+// NewGradleBuildArgumentsProvider creates a new instance for Gradle.  The default arguments exclude the test task
+// unless tests are run, pass $BP_GRADLE_PROPERTIES as project properties, and run $BP_GRADLE_TASKS or build.  If module
+// is not empty, the tasks are run in the project at that path only.  $BP_GRADLE_BUILD_ARGUMENTS replaces them, falling
+// back to $BP_BUILD_ARGUMENTS.
+func NewGradleBuildArgumentsProvider(runTests bool, module string) (BuildArgumentsProvider, error) {
+	key := argumentsKey("BP_GRADLE_BUILD_ARGUMENTS")
+
+	if err := structured(key, "BP_GRADLE_TASKS", "BP_GRADLE_PROPERTIES"); err != nil {
+		return BuildArgumentsProvider{}, err
+	}
+
+	var args []string
+	if !runTests {
+		args = append(args, "-x", "test")
+	}
+
+	properties, _, err := config.Words("BP_GRADLE_PROPERTIES")
+	if err != nil {
+		return BuildArgumentsProvider{}, err
+	}
+
+	for _, p := range properties {
+		args = append(args, "-P"+p)
+	}
+
+	tasks, err := targets("BP_GRADLE_TASKS")
+	if err != nil {
+		return BuildArgumentsProvider{}, err
+	}
 
-	if c, ok := os.LookupEnv("BP_BUILD_ARGUMENTS"); ok {
-		if args, err := shellwords.Parse(c); err != nil {
-			return BuildArgumentsProvider{}, err
-		} else {
-			p.Arguments = args
+	if project := gradleProjectPath(module); project != ":" {
+		for i, t := range tasks {
+			if !strings.HasPrefix(t, "-") && !strings.Contains(t, ":") {
+				tasks[i] = project + ":" + t
+			}
 		}
 	}
 
+	return newBuildArgumentsProvider(key, append(args, tasks...)...)
+}
+
+// NewMavenBuildArgumentsProvider creates a new instance for Maven.  The default arguments skip tests unless tests are
+// run, activate $BP_MAVEN_PROFILES, pass $BP_MAVEN_PROPERTIES as system properties, build only module and the modules
+// it depends on if module is not empty, and run $BP_MAVEN_GOALS or package.  $BP_MAVEN_BUILD_ARGUMENTS replaces them,
+// falling back to $BP_BUILD_ARGUMENTS.
+func NewMavenBuildArgumentsProvider(runTests bool, module string) (BuildArgumentsProvider, error) {
+	key := argumentsKey("BP_MAVEN_BUILD_ARGUMENTS")
+
+	if err := structured(key, "BP_MAVEN_GOALS", "BP_MAVEN_PROFILES", "BP_MAVEN_PROPERTIES"); err != nil {
+		return BuildArgumentsProvider{}, err
+	}
+
+	properties, _, err := config.Words("BP_MAVEN_PROPERTIES")
+	if err != nil {
+		return BuildArgumentsProvider{}, err
+	}
+
+	var args []string
+	if runTests {
+		for _, p := range properties {
+			if k := strings.SplitN(p, "=", 2)[0]; k == "maven.test.skip" || k == "skipTests" {
+				return BuildArgumentsProvider{}, fmt.Errorf("$BP_MAVEN_PROPERTIES %s conflicts with $BP_RUN_TESTS", k)
+			}
+		}
+	} else {
+		args = append(args, "-Dmaven.test.skip=true")
+	}
+
+	if profiles := config.Values("BP_MAVEN_PROFILES"); len(profiles) > 0 {
+		args = append(args, "-P"+strings.Join(profiles, ","))
+	}
+
+	for _, p := range properties {
+		args = append(args, "-D"+p)
+	}
+
+	if module != "" {
+		args = append(args, "-pl", module, "-am")
+	}
+
+	goals, err := targets("BP_MAVEN_GOALS")
+	if err != nil {
+		return BuildArgumentsProvider{}, err
+	}
+
+	return newBuildArgumentsProvider(key, append(args, goals...)...)
+}
+
+// newBuildArgumentsProvider creates a new instance using the default arguments unless the arguments in key replace them.
+// Arguments in $BP_BUILD_ARGUMENTS_APPEND are appended in either case.
+func newBuildArgumentsProvider(key string, defaultArguments ...string) (BuildArgumentsProvider, error) {
+	p := BuildArgumentsProvider{defaultArguments}
+
+	if args, ok, err := config.Words(key); err != nil {
+		return BuildArgumentsProvider{}, err
+	} else if ok {
+		p.Arguments = args
+	}
+
+	if args, ok, err := config.Words("BP_BUILD_ARGUMENTS_APPEND"); err != nil {
+		return BuildArgumentsProvider{}, err
+	} else if ok {
+		p.Arguments = append(p.Arguments, args...)
+	}
+
 	return p, nil
 }
+
+// argumentsKey returns the build system specific key if it is set and $BP_BUILD_ARGUMENTS otherwise.
+func argumentsKey(specific string) string {
+	if _, ok := config.Lookup(specific); ok {
+		return specific
+	}
+
+	return "BP_BUILD_ARGUMENTS"
+}
+
+// structured returns an error if any of the structured arguments in keys are combined with the arguments in key, which
+// replace the default arguments that they are composed into.
+func structured(key string, keys ...string) error {
+	if _, ok := config.Lookup(key); !ok {
+		return nil
+	}
+
+	var conflicts []string
+	for _, k := range keys {
+		if _, ok := config.Lookup(k); ok {
+			conflicts = append(conflicts, "$"+k)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("$%s cannot be combined with %s", key, strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+// gradleProjectPath returns the Gradle project path, such as :services:api, of the project in a slash-separated module
+// directory, such as services/api.
+func gradleProjectPath(module string) string {
+	var segments []string
+
+	for _, s := range strings.Split(filepath.ToSlash(module), "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+
+	return ":" + strings.Join(segments, ":")
+}
+
+// targets returns the goals or tasks in an environment variable, or its default if it is not set.
+func targets(key string) ([]string, error) {
+	t, ok, err := config.Words(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && len(t) == 0 {
+		return nil, fmt.Errorf("invalid $%s: must contain at least one value", key)
+	}
+
+	return t, nil
+}
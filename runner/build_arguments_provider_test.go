@@ -31,21 +31,183 @@ func TestBuildArgumentsProvider(t *testing.T) {
 
 		g := gomega.NewWithT(t)
 
-		it("parses value from $BP_BUILD_ARGUMENTS", func() {
-			defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "test configured arguments")()
+		when("Gradle", func() {
 
-			p, err := runner.NewBuildArgumentsProvider("test", "default", "arguments")
+			it("uses default arguments", func() {
+				p, err := runner.NewGradleBuildArgumentsProvider(false, "")
 
-			g.Expect(err).NotTo(gomega.HaveOccurred())
-			g.Expect(p.Arguments).To(gomega.Equal([]string{"test", "configured", "arguments"}))
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"-x", "test", "build"}))
+			})
 
+			it("composes structured arguments", func() {
+				defer test.ReplaceEnv(t, "BP_GRADLE_TASKS", "clean bootJar")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_PROPERTIES", "test-key-1=test-value-1 'test-key-2=test value 2'")()
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS_APPEND", "--info")()
+
+				p, err := runner.NewGradleBuildArgumentsProvider(true, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{
+					"-Ptest-key-1=test-value-1", "-Ptest-key-2=test value 2", "clean", "bootJar", "--info",
+				}))
+			})
+
+			it("runs tasks in module project", func() {
+				p, err := runner.NewGradleBuildArgumentsProvider(false, "test-module")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"-x", "test", ":test-module:build"}))
+			})
+
+			it("runs tasks in nested module project", func() {
+				defer test.ReplaceEnv(t, "BP_GRADLE_TASKS", "clean bootJar :other:check --parallel")()
+
+				p, err := runner.NewGradleBuildArgumentsProvider(true, "./test-services/test-module/")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{
+					":test-services:test-module:clean", ":test-services:test-module:bootJar", ":other:check", "--parallel",
+				}))
+			})
+
+			it("fails with malformed property", func() {
+				defer test.ReplaceEnv(t, "BP_GRADLE_PROPERTIES", "test-key")()
+
+				_, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).To(gomega.MatchError("invalid $BP_GRADLE_PROPERTIES: test-key is not of the form key=value"))
+			})
+
+			it("fails with empty tasks", func() {
+				defer test.ReplaceEnv(t, "BP_GRADLE_TASKS", "")()
+
+				_, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).To(gomega.MatchError("invalid $BP_GRADLE_TASKS: must contain at least one value"))
+			})
+
+			it("fails when combined with $BP_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "build")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_TASKS", "bootJar")()
+
+				_, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).To(gomega.MatchError("$BP_BUILD_ARGUMENTS cannot be combined with $BP_GRADLE_TASKS"))
+			})
+
+			it("parses value from $BP_GRADLE_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "package")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_BUILD_ARGUMENTS", "bootJar --info")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_BUILD_ARGUMENTS", "install")()
+
+				p, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"bootJar", "--info"}))
+			})
+
+			it("falls back to $BP_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "test configured arguments")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_BUILD_ARGUMENTS", "install")()
+
+				p, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"test", "configured", "arguments"}))
+			})
+
+			it("appends value from $BP_BUILD_ARGUMENTS_APPEND to replaced arguments", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "test configured arguments")()
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS_APPEND", "test appended")()
+
+				p, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"test", "configured", "arguments", "test", "appended"}))
+			})
+
+			it("fails when $BP_GRADLE_BUILD_ARGUMENTS is combined with structured arguments", func() {
+				defer test.ReplaceEnv(t, "BP_GRADLE_BUILD_ARGUMENTS", "build")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_PROPERTIES", "test-key=test-value")()
+
+				_, err := runner.NewGradleBuildArgumentsProvider(false, "")
+
+				g.Expect(err).To(gomega.MatchError("$BP_GRADLE_BUILD_ARGUMENTS cannot be combined with $BP_GRADLE_PROPERTIES"))
+			})
 		})
 
-		it("uses default arguments", func() {
-			p, err := runner.NewBuildArgumentsProvider("test", "default", "arguments")
+		when("Maven", func() {
+
+			it("uses default arguments", func() {
+				p, err := runner.NewMavenBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"-Dmaven.test.skip=true", "package"}))
+			})
+
+			it("composes structured arguments", func() {
+				defer test.ReplaceEnv(t, "BP_MAVEN_GOALS", "clean install")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_PROFILES", "test-profile-1, !test-profile-2")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_PROPERTIES", "test-key=test-value")()
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS_APPEND", "--batch-mode")()
+
+				p, err := runner.NewMavenBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{
+					"-Dmaven.test.skip=true", "-Ptest-profile-1,!test-profile-2", "-Dtest-key=test-value", "clean", "install",
+					"--batch-mode",
+				}))
+			})
+
+			it("builds only module and its dependencies", func() {
+				p, err := runner.NewMavenBuildArgumentsProvider(false, "test-module")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{
+					"-Dmaven.test.skip=true", "-pl", "test-module", "-am", "package",
+				}))
+			})
+
+			it("fails when skipping tests conflicts with running tests", func() {
+				defer test.ReplaceEnv(t, "BP_MAVEN_PROPERTIES", "skipTests=true")()
+
+				_, err := runner.NewMavenBuildArgumentsProvider(true, "")
+
+				g.Expect(err).To(gomega.MatchError("$BP_MAVEN_PROPERTIES skipTests conflicts with $BP_RUN_TESTS"))
+			})
+
+			it("fails when combined with $BP_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "package")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_GOALS", "install")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_PROFILES", "test-profile")()
+
+				_, err := runner.NewMavenBuildArgumentsProvider(false, "")
+
+				g.Expect(err).To(gomega.MatchError("$BP_BUILD_ARGUMENTS cannot be combined with $BP_MAVEN_GOALS, $BP_MAVEN_PROFILES"))
+			})
+
+			it("parses value from $BP_MAVEN_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "build")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_BUILD_ARGUMENTS", "bootJar")()
+				defer test.ReplaceEnv(t, "BP_MAVEN_BUILD_ARGUMENTS", "clean install")()
+
+				p, err := runner.NewMavenBuildArgumentsProvider(false, "")
+
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"clean", "install"}))
+			})
+
+			it("falls back to $BP_BUILD_ARGUMENTS", func() {
+				defer test.ReplaceEnv(t, "BP_BUILD_ARGUMENTS", "verify")()
+				defer test.ReplaceEnv(t, "BP_GRADLE_BUILD_ARGUMENTS", "bootJar")()
+
+				p, err := runner.NewMavenBuildArgumentsProvider(false, "")
 
-			g.Expect(err).NotTo(gomega.HaveOccurred())
-			g.Expect(p.Arguments).To(gomega.Equal([]string{"test", "default", "arguments"}))
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(p.Arguments).To(gomega.Equal([]string{"verify"}))
+			})
 		})
 	}, spec.Report(report.Terminal{}))
 }
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+)
+
+// The formats of built artifacts.
+const (
+	directoryFormat = "directory"
+	tarFormat       = "tar"
+	tarGzFormat     = "tar.gz"
+	zipFormat       = "zip"
+)
+
+// cachedArtifacts are the names of the cached copy of a built artifact, by format, in the order they are looked for.
+var cachedArtifacts = []struct {
+	format string
+	name   string
+}{
+	{zipFormat, "application.zip"},
+	{tarGzFormat, "application.tar.gz"},
+	{tarFormat, "application.tar"},
+	{directoryFormat, "application"},
+}
+
+// artifactFormat returns the format of a built artifact.  Files that are not tar archives are treated as zip files,
+// as JARs and WARs are.
+func artifactFormat(artifact string) (string, error) {
+	info, err := os.Stat(artifact)
+	if err != nil {
+		return "", err
+	}
+
+	switch name := strings.ToLower(info.Name()); {
+	case info.IsDir():
+		return directoryFormat, nil
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return tarGzFormat, nil
+	case strings.HasSuffix(name, ".tar"):
+		return tarFormat, nil
+	default:
+		return zipFormat, nil
+	}
+}
+
+// archiveEntries returns the slash-separated names of the entries of a tar or zip archive.
+func archiveEntries(artifact string, format string) ([]string, error) {
+	var names []string
+
+	if format == zipFormat {
+		z, err := zip.OpenReader(artifact)
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+
+		for _, f := range z.File {
+			names = append(names, f.Name)
+		}
+
+		return names, nil
+	}
+
+	f, err := os.Open(artifact)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var in io.Reader = f
+	if format == tarGzFormat {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		in = gz
+	}
+
+	t := tar.NewReader(in)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return names, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		names = append(names, strings.TrimPrefix(h.Name, "./"))
+	}
+}
+
+// distributionRoot returns the single top-level directory of an archive if it is an application distribution, such as
+// those created by the Gradle application plugin, containing bin and lib directories.  Otherwise returns empty.
+func distributionRoot(entries []string) string {
+	root := ""
+	bin, lib := false, false
+
+	for _, e := range entries {
+		s := strings.SplitN(e, "/", 3)
+		if len(s) < 2 || (root != "" && s[0] != root) {
+			return ""
+		}
+		root = s[0]
+
+		if len(s) == 3 {
+			bin = bin || s[1] == "bin"
+			lib = lib || s[1] == "lib"
+		}
+	}
+
+	if !bin || !lib {
+		return ""
+	}
+
+	return root
+}
+
+// cacheArtifact copies a built artifact to root, preserving file modes.
+func cacheArtifact(artifact string, root string) error {
+	format, err := artifactFormat(artifact)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cachedArtifacts {
+		if c.format != format {
+			continue
+		}
+
+		if format == directoryFormat {
+			return copyTree(artifact, filepath.Join(root, c.name))
+		}
+
+		return helper.CopyFile(artifact, filepath.Join(root, c.name))
+	}
+
+	return fmt.Errorf("unsupported format %s", format)
+}
+
+// expandArtifact expands the built artifact cached in root to destination.  The top-level directory of an application
+// distribution is removed and the files in bin are made executable.
+func expandArtifact(root string, destination string) error {
+	for _, c := range cachedArtifacts {
+		artifact := filepath.Join(root, c.name)
+
+		if ok, err := helper.FileExists(artifact); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if err := expand(artifact, c.format, destination); err != nil {
+			return err
+		}
+
+		return makeExecutable(filepath.Join(destination, "bin"))
+	}
+
+	return fmt.Errorf("unable to find cached application in %s", root)
+}
+
+func expand(artifact string, format string, destination string) error {
+	if format == directoryFormat {
+		return copyTree(artifact, destination)
+	}
+
+	entries, err := archiveEntries(artifact, format)
+	if err != nil {
+		return err
+	}
+
+	strip := 0
+	if distributionRoot(entries) != "" {
+		strip = 1
+	}
+
+	switch format {
+	case tarFormat:
+		return helper.ExtractTar(artifact, destination, strip)
+	case tarGzFormat:
+		return helper.ExtractTarGz(artifact, destination, strip)
+	default:
+		return helper.ExtractZip(artifact, destination, strip)
+	}
+}
+
+// makeExecutable makes the regular files directly within directory executable, if it exists.
+func makeExecutable(directory string) error {
+	files, err := ioutil.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.Mode().IsRegular() {
+			if err := os.Chmod(filepath.Join(directory, f.Name()), f.Mode().Perm()|0555); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -21,106 +21,544 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
 	"github.com/magiconair/properties"
 )
 
+// classifiers are the suffixes of artifacts that are built alongside an application but are not the application.
+var classifiers = []string{"-javadoc", "-plain", "-sources"}
+
+// versioned matches a name ending in a version segment, such as application-1.0.0 or application-1.0.0-SNAPSHOT.
+var versioned = regexp.MustCompile(`-\d[^-]*(-SNAPSHOT)?$`)
+
+// ArtifactKind is the kind of a built artifact.  Kinds that are more likely to be the application rank higher.
+type ArtifactKind int
+
+const (
+	// UnknownArtifact is an artifact that is neither executable nor a WAR.
+	UnknownArtifact ArtifactKind = iota
+
+	// DirectoryArtifact is a directory or tar archive that is not a distribution.
+	DirectoryArtifact
+
+	// WARArtifact is a WAR.
+	WARArtifact
+
+	// DistributionArtifact is a directory or archive of an application distribution, containing bin and lib.
+	DistributionArtifact
+
+	// ExecutableArtifact is a JAR with a Main-Class.
+	ExecutableArtifact
+
+	// SpringBootArtifact is a Spring Boot JAR or WAR with a Start-Class or BOOT-INF.
+	SpringBootArtifact
+)
+
+func (a ArtifactKind) String() string {
+	switch a {
+	case DirectoryArtifact:
+		return "directory"
+	case WARArtifact:
+		return "WAR"
+	case DistributionArtifact:
+		return "distribution"
+	case ExecutableArtifact:
+		return "executable JAR"
+	case SpringBootArtifact:
+		return "Spring Boot application"
+	default:
+		return "neither executable JAR nor WAR"
+	}
+}
+
 // BuiltArtifactProvider returns the artifact built as part of running a build system.
 type BuiltArtifactProvider struct {
-	target string
+	bases      []string
+	exclusions []ignorePattern
+	logger     logger.Logger
+	module     string
+	patterns   []ignorePattern
+	reported   string
+	resolver   func(root string) (string, error)
+	target     string
 }
 
-// Get returns the built artifact if exactly one exists.  If less than or more than one exists, returns an error.
+// Get returns the built artifact.  Candidates that are classifier artifacts or that match $BP_BUILT_ARTIFACT_EXCLUDE
+// are rejected.  If more than one candidate remains, the candidate of the highest ranking kind is returned, preferring
+// the artifact determined by the resolver.  If no candidate or more than one candidate of that kind exists, returns an
+// error.
 func (b BuiltArtifactProvider) Get(application application.Application) (string, error) {
-	candidates, err := filepath.Glob(filepath.Join(application.Root, b.target))
+	resolved, rejected, err := b.resolve(application.Root)
+	if err != nil {
+		return "", err
+	}
+
+	candidates, err := b.reportedCandidates(application.Root)
 	if err != nil {
 		return "", err
 	}
 
+	if len(candidates) == 0 {
+		candidates, err = b.candidates(application.Root)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var remaining []string
+	if resolved != "" {
+		remaining = append(remaining, resolved)
+	}
+
+	for _, c := range candidates {
+		if c == resolved || c == rejected {
+			continue
+		}
+
+		if excluded, err := b.isExcluded(application.Root, c); err != nil {
+			return "", err
+		} else if !excluded {
+			remaining = append(remaining, c)
+		}
+	}
+
 	var artifacts []string
 
-	if len(candidates) == 1 {
-		artifacts = candidates
+	if len(remaining) == 1 {
+		b.logger.Debug("Selected %s: only remaining candidate", remaining[0])
+		artifacts = remaining
 	} else {
-		for _, c := range candidates {
-			if i, err := b.isInterestingFile(c); err != nil {
+		kinds := make(map[string]ArtifactKind, len(remaining))
+		best := UnknownArtifact
+
+		for _, c := range remaining {
+			k, err := b.kind(c)
+			if err != nil {
 				return "", err
-			} else if i {
+			}
+
+			kinds[c] = k
+			if k > best {
+				best = k
+			}
+		}
+
+		preferred := ""
+		if resolved != "" && kinds[resolved] == best {
+			preferred = resolved
+		}
+
+		for _, c := range remaining {
+			switch {
+			case best == UnknownArtifact:
+				b.logger.Body("Rejected %s: %s", c, kinds[c])
+			case kinds[c] < best:
+				b.logger.Body("Rejected %s: %s, preferring %s", c, kinds[c], best)
+			case preferred != "" && c != preferred:
+				b.logger.Body("Rejected %s: %s, preferring resolved %s", c, kinds[c], preferred)
+			default:
+				b.logger.Body("Accepted %s: %s", c, kinds[c])
 				artifacts = append(artifacts, c)
 			}
 		}
 	}
 
 	if len(artifacts) != 1 {
-		sort.Strings(candidates)
 		return "", fmt.Errorf("unable to find built artifact (executable JAR or WAR) in %s, candidates: %s", b.target, candidates)
 	}
 
 	return artifacts[0], nil
 }
 
-func (BuiltArtifactProvider) isInterestingEntry(f *zip.File) (bool, error) {
-	if f.Name == "WEB-INF/" && f.FileInfo().IsDir() {
-		return true, nil
+// Reported returns a copy of the provider that selects from the artifacts listed in file, as reported by the build
+// system, rather than those matching the patterns.
+func (b BuiltArtifactProvider) Reported(file string) BuiltArtifactProvider {
+	b.reported = file
+	return b
+}
+
+// reportedCandidates returns the sorted artifacts reported by the build system that exist within the application root
+// or $BP_BUILT_MODULE.  If no artifacts were reported, returns an empty list.
+func (b BuiltArtifactProvider) reportedCandidates(root string) ([]string, error) {
+	if b.reported == "" {
+		return nil, nil
+	}
+
+	if ok, err := helper.FileExists(b.reported); err != nil || !ok {
+		return nil, err
+	}
+
+	c, err := ioutil.ReadFile(b.reported)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]bool)
+	for _, a := range strings.Split(string(c), "\n") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+
+		if base := filepath.Join(root, filepath.FromSlash(b.module)); !isWithin(base, a) {
+			b.logger.Body("Rejected %s: not within %s", a, base)
+			continue
+		}
+
+		if ok, err := helper.FileExists(a); err != nil {
+			return nil, err
+		} else if ok {
+			matches[a] = true
+		}
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for c := range matches {
+		candidates = append(candidates, c)
+	}
+	sort.Strings(candidates)
+
+	if len(candidates) > 0 {
+		b.logger.Body("Selecting from %d artifacts reported by the build system", len(candidates))
+	}
+
+	return candidates, nil
+}
+
+// resolve returns the artifact determined by the resolver if it exists.  The artifact is rejected, and returned as
+// such so that it is not selected by searching either, if it is a classifier artifact, matches
+// $BP_BUILT_ARTIFACT_EXCLUDE, or is of an unknown kind, such as the JAR that a Quarkus, shade, or assembly build
+// replaces.  If the artifact cannot be determined, the reason is logged.
+func (b BuiltArtifactProvider) resolve(root string) (string, string, error) {
+	if b.resolver == nil {
+		return "", "", nil
+	}
+
+	artifact, err := b.resolver(root)
+	if err == nil {
+		var ok bool
+		if ok, err = helper.FileExists(artifact); err == nil && !ok {
+			err = fmt.Errorf("%s does not exist", artifact)
+		}
+	}
+
+	if err != nil {
+		b.logger.Body("Unable to resolve built artifact, searching %s: %s", b.target, err)
+		return "", "", nil
+	}
+
+	b.logger.Body("Resolved built artifact %s", artifact)
+
+	if excluded, err := b.isExcluded(root, artifact); err != nil {
+		return "", "", err
+	} else if excluded {
+		return "", artifact, nil
+	}
+
+	if k, err := b.kind(artifact); err != nil {
+		return "", "", err
+	} else if k == UnknownArtifact {
+		b.logger.Body("Rejected %s: %s, searching %s", artifact, k, b.target)
+		return "", artifact, nil
+	}
+
+	return artifact, "", nil
+}
+
+// candidates returns the sorted files and directories beneath root that match the patterns.  Only the directories that
+// a pattern can match within are searched and a matching directory is not searched further.
+func (b BuiltArtifactProvider) candidates(root string) ([]string, error) {
+	matches := make(map[string]bool)
+
+	for _, base := range b.bases {
+		dir := filepath.Join(root, filepath.FromSlash(base))
+
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && file == dir {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if info.IsDir() && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			rel, err := relativePath(root, file)
+			if err != nil {
+				return err
+			}
+
+			if rel != "." && matchIgnorePatterns(b.patterns, rel, info.IsDir(), false) {
+				matches[file] = true
+
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for c := range matches {
+		candidates = append(candidates, c)
+	}
+	sort.Strings(candidates)
+
+	return candidates, nil
+}
+
+// isExcluded returns whether a candidate is a classifier artifact or matches $BP_BUILT_ARTIFACT_EXCLUDE.  Patterns
+// are matched after classifiers, so a negated pattern includes a classifier artifact.
+func (b BuiltArtifactProvider) isExcluded(root string, candidate string) (bool, error) {
+	rel, err := relativePath(root, candidate)
+	if err != nil {
+		return false, err
+	}
+
+	classifier, err := b.classifier(candidate)
+	if err != nil {
+		return false, err
+	}
+
+	excluded := matchIgnorePatterns(b.exclusions, rel, false, classifier != "")
+
+	switch {
+	case excluded && classifier != "":
+		b.logger.Body("Rejected %s: %s classifier artifact", candidate, classifier)
+	case excluded:
+		b.logger.Body("Rejected %s: matches $BP_BUILT_ARTIFACT_EXCLUDE", candidate)
+	case classifier != "":
+		b.logger.Debug("Included %s %s classifier artifact: matches $BP_BUILT_ARTIFACT_EXCLUDE", candidate, classifier)
+	}
+
+	return excluded, nil
+}
+
+// classifier returns the classifier of a candidate, if any.  A suffix is only a classifier if it follows a version
+// segment, as in application-1.0.0-sources.jar, or if the artifact without it is next to the candidate, as
+// application-plain.jar is next to application.jar.  An application named like event-sources.jar is not rejected.
+func (b BuiltArtifactProvider) classifier(candidate string) (string, error) {
+	ext := filepath.Ext(candidate)
+	name := strings.TrimSuffix(filepath.Base(candidate), ext)
+
+	for _, c := range classifiers {
+		if !strings.HasSuffix(name, c) {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, c)
+		if versioned.MatchString(base) {
+			return c, nil
+		}
+
+		if ok, err := helper.FileExists(filepath.Join(filepath.Dir(candidate), base+ext)); err != nil {
+			return "", err
+		} else if ok {
+			return c, nil
+		}
+
+		b.logger.Debug("Not treating %s as a %s classifier artifact: no version or %s%s next to it", candidate, c, base,
+			ext)
+	}
+
+	return "", nil
+}
+
+func (BuiltArtifactProvider) entryKind(f *zip.File) (ArtifactKind, error) {
+	if strings.HasPrefix(f.Name, "BOOT-INF/") {
+		return SpringBootArtifact, nil
+	}
+
+	if strings.HasPrefix(f.Name, "WEB-INF/") {
+		return WARArtifact, nil
 	}
 
 	if f.Name == "META-INF/MANIFEST.MF" {
 		m, err := f.Open()
 		if err != nil {
-			return false, err
+			return UnknownArtifact, err
 		}
 		defer m.Close()
 
 		b, err := ioutil.ReadAll(m)
 		if err != nil {
-			return false, err
+			return UnknownArtifact, err
 		}
 
 		p, err := properties.Load(b, properties.UTF8)
 		if err != nil {
-			return false, nil
+			return UnknownArtifact, nil
+		}
+
+		if _, ok := p.Get("Start-Class"); ok {
+			return SpringBootArtifact, nil
 		}
 
 		if _, ok := p.Get("Main-Class"); ok {
-			return true, nil
+			return ExecutableArtifact, nil
 		}
 	}
 
-	return false, nil
+	return UnknownArtifact, nil
 }
 
-func (b BuiltArtifactProvider) isInterestingFile(f string) (bool, error) {
+// kind returns the highest ranking kind indicated by any entry of an artifact.  An archive that cannot be read, such
+// as a file that is not a ZIP despite its name, is an UnknownArtifact so that the remaining candidates are ranked.
+func (b BuiltArtifactProvider) kind(f string) (ArtifactKind, error) {
+	format, err := artifactFormat(f)
+	if err != nil {
+		return UnknownArtifact, err
+	}
+
+	switch format {
+	case directoryFormat:
+		if ok, err := helper.FileExists(filepath.Join(f, "bin")); err != nil {
+			return UnknownArtifact, err
+		} else if ok {
+			return DistributionArtifact, nil
+		}
+		return DirectoryArtifact, nil
+	case tarFormat, tarGzFormat:
+		entries, err := archiveEntries(f, format)
+		if err != nil {
+			b.logger.Debug("Unable to read %s: %s", f, err)
+			return UnknownArtifact, nil
+		}
+
+		if distributionRoot(entries) != "" {
+			return DistributionArtifact, nil
+		}
+		return DirectoryArtifact, nil
+	}
+
 	z, err := zip.OpenReader(f)
 	if err != nil {
-		return false, err
+		b.logger.Debug("Unable to read %s: %s", f, err)
+		return UnknownArtifact, nil
 	}
 	defer z.Close()
 
+	var entries []string
 	for _, f := range z.File {
-		if i, err := b.isInterestingEntry(f); err != nil {
-			return false, err
-		} else if i {
-			return true, nil
+		entries = append(entries, f.Name)
+	}
+
+	if distributionRoot(entries) != "" {
+		return DistributionArtifact, nil
+	}
+
+	kind := UnknownArtifact
+	for _, e := range z.File {
+		if k, err := b.entryKind(e); err != nil {
+			b.logger.Debug("Unable to read %s in %s: %s", e.Name, f, err)
+			return UnknownArtifact, nil
+		} else if k > kind {
+			kind = k
 		}
 	}
 
-	return false, nil
+	return kind, nil
 }
 
-// NewBuiltArtifactProvider creates a new instance using the default target if not otherwise configured.
-func NewBuiltArtifactProvider(defaultTarget ...string) BuiltArtifactProvider {
-	if target, ok := os.LookupEnv("BP_BUILT_ARTIFACT"); ok {
-		return BuiltArtifactProvider{target}
+// BuiltModule returns the slash-separated module, relative to the application root, configured with $BP_BUILT_MODULE.
+// If it is not set, returns empty.
+func BuiltModule() (string, error) {
+	v, ok := config.Lookup("BP_BUILT_MODULE")
+	if !ok {
+		return "", nil
+	}
+
+	module := path.Clean(filepath.ToSlash(v))
+	if path.IsAbs(module) || module == ".." || strings.HasPrefix(module, "../") {
+		return "", fmt.Errorf("invalid $BP_BUILT_MODULE: %s is not within the application root", v)
+	}
+
+	if module == "." {
+		return "", nil
+	}
+
+	return module, nil
+}
+
+// NewBuiltArtifactProvider creates a new instance using the default target within module if not otherwise configured.
+// $BP_BUILT_ARTIFACT is a list of glob patterns relative to the application root.  A ** path segment matches zero or
+// more directories and patterns prefixed with ! exclude files matched by earlier patterns.
+func NewBuiltArtifactProvider(logger logger.Logger, module string,
+	defaultTarget ...string) (BuiltArtifactProvider, error) {
+
+	exclusions, err := parseIgnorePatterns(config.Values("BP_BUILT_ARTIFACT_EXCLUDE"), "", false)
+	if err != nil {
+		return BuiltArtifactProvider{}, fmt.Errorf("invalid $BP_BUILT_ARTIFACT_EXCLUDE: %w", err)
+	}
+
+	target, ok := config.Lookup("BP_BUILT_ARTIFACT")
+	if !ok {
+		target = path.Join(module, filepath.ToSlash(filepath.Join(defaultTarget...)))
 	}
 
-	target := filepath.Join(defaultTarget...)
+	values := config.Values("BP_BUILT_ARTIFACT")
+	if !ok {
+		values = []string{target}
+	}
+
+	var bases []string
+	for _, v := range values {
+		p := strings.TrimPrefix(v, "!")
+
+		for _, s := range strings.Split(p, "/") {
+			if s == ".." {
+				return BuiltArtifactProvider{},
+					fmt.Errorf("invalid $BP_BUILT_ARTIFACT: %s is not within the application root", v)
+			}
+		}
+
+		if p == v {
+			bases = append(bases, globBase(strings.TrimPrefix(p, "/")))
+		}
+	}
+
+	patterns, err := parseIgnorePatterns(values, "", true)
+	if err != nil {
+		return BuiltArtifactProvider{}, fmt.Errorf("invalid $BP_BUILT_ARTIFACT: %w", err)
+	}
+
+	if len(bases) == 0 {
+		return BuiltArtifactProvider{}, fmt.Errorf("invalid $BP_BUILT_ARTIFACT: %s contains only exclusions", target)
+	}
+
+	return BuiltArtifactProvider{bases, exclusions, logger, module, patterns, "", nil, target}, nil
+}
+
+// NewMavenBuiltArtifactProvider creates a new instance that resolves the artifact from the pom.xml of the application
+// or module, unless $BP_BUILT_ARTIFACT is set.  If the artifact cannot be resolved, target/*.[jw]ar is searched.
+func NewMavenBuiltArtifactProvider(logger logger.Logger, module string) (BuiltArtifactProvider, error) {
+	b, err := NewBuiltArtifactProvider(logger, module, "target", "*.[jw]ar")
+	if err != nil {
+		return BuiltArtifactProvider{}, err
+	}
+
+	if _, ok := config.Lookup("BP_BUILT_ARTIFACT"); ok {
+		return b, nil
+	}
 
-	if module, ok := os.LookupEnv("BP_BUILT_MODULE"); ok {
-		target = filepath.Join(module, target)
+	b.resolver = func(root string) (string, error) {
+		return mavenArtifact(filepath.Join(root, filepath.FromSlash(b.module)))
 	}
 
-	return BuiltArtifactProvider{target}
+	return b, nil
 }
@@ -39,55 +39,430 @@ func TestBuiltArtifactProvider(t *testing.T) {
 			f = test.NewBuildFactory(t)
 		})
 
+		copyStub := func(stub string, name string) {
+			test.CopyFile(t, filepath.Join("testdata", stub), filepath.Join(f.Build.Application.Root, name))
+		}
+
+		get := func(defaultTarget ...string) (string, error) {
+			b, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", defaultTarget...)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			return b.Get(f.Build.Application)
+		}
+
 		it("fails with no files", func() {
-			_, err := runner.NewBuiltArtifactProvider("*.[jw]ar").Get(f.Build.Application)
+			_, err := get("*.[jw]ar")
 
 			g.Expect(err).To(gomega.MatchError("unable to find built artifact (executable JAR or WAR) in *.[jw]ar, candidates: []"))
 		})
 
-		it("fails with multiple candidates", func() {
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-application.jar"))
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.war"),
-				filepath.Join(f.Build.Application.Root, "stub-application.war"))
-			test.CopyFile(t, filepath.Join("testdata", "stub-executable.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-executable.jar"))
+		it("fails with multiple candidates of the highest ranking kind", func() {
+			copyStub("stub-application.jar", "stub-application.jar")
+			copyStub("stub-executable.jar", "stub-executable-1.jar")
+			copyStub("stub-executable.jar", "stub-executable-2.jar")
 
-			_, err := runner.NewBuiltArtifactProvider("*.[jw]ar").Get(f.Build.Application)
+			_, err := get("*.[jw]ar")
 
 			g.Expect(err).To(gomega.MatchError(
 				fmt.Sprintf("unable to find built artifact (executable JAR or WAR) in *.[jw]ar, candidates: [%s %s %s]",
 					filepath.Join(f.Build.Application.Root, "stub-application.jar"),
-					filepath.Join(f.Build.Application.Root, "stub-application.war"),
-					filepath.Join(f.Build.Application.Root, "stub-executable.jar"))))
+					filepath.Join(f.Build.Application.Root, "stub-executable-1.jar"),
+					filepath.Join(f.Build.Application.Root, "stub-executable-2.jar"))))
+		})
+
+		it("fails with multiple candidates that are neither executable JARs nor WARs", func() {
+			copyStub("stub-application.jar", "stub-application-1.jar")
+			copyStub("stub-application.jar", "stub-application-2.jar")
+
+			_, err := get("*.[jw]ar")
+
+			g.Expect(err).To(gomega.HaveOccurred())
 		})
 
 		it("passes with a single candidate", func() {
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-application.jar"))
+			copyStub("stub-application.jar", "stub-application.jar")
 
-			g.Expect(runner.NewBuiltArtifactProvider("*.[jw]ar").Get(f.Build.Application)).
-				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-application.jar")))
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-application.jar")))
 		})
 
 		it("passes with single executable JAR", func() {
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-application.jar"))
-			test.CopyFile(t, filepath.Join("testdata", "stub-executable.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-executable.jar"))
+			copyStub("stub-application.jar", "stub-application.jar")
+			copyStub("stub-executable.jar", "stub-executable.jar")
 
-			g.Expect(runner.NewBuiltArtifactProvider("*.[jw]ar").Get(f.Build.Application)).
-				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-executable.jar")))
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-executable.jar")))
 		})
 
 		it("passes with single WAR", func() {
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.jar"),
-				filepath.Join(f.Build.Application.Root, "stub-application.jar"))
-			test.CopyFile(t, filepath.Join("testdata", "stub-application.war"),
-				filepath.Join(f.Build.Application.Root, "stub-application.war"))
+			copyStub("stub-application.jar", "stub-application.jar")
+			copyStub("stub-application.war", "stub-application.war")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-application.war")))
+		})
+
+		it("prefers executable JAR to WAR", func() {
+			copyStub("stub-application.war", "stub-application.war")
+			copyStub("stub-executable.jar", "stub-executable.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-executable.jar")))
+		})
+
+		it("prefers Spring Boot application to executable JAR and WAR", func() {
+			copyStub("stub-application.war", "stub-application.war")
+			copyStub("stub-executable.jar", "stub-executable.jar")
+			copyStub("stub-spring-boot.jar", "stub-spring-boot.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-spring-boot.jar")))
+		})
+
+		it("rejects classifier artifacts", func() {
+			copyStub("stub-spring-boot.jar", "stub-1.0.0-javadoc.jar")
+			copyStub("stub-spring-boot.jar", "stub-1.0.0-plain.jar")
+			copyStub("stub-spring-boot.jar", "stub-1.0.0-sources.jar")
+			copyStub("stub-executable.jar", "stub-1.0.0.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-1.0.0.jar")))
+		})
+
+		it("rejects $BP_BUILT_ARTIFACT_EXCLUDE", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT_EXCLUDE", "*-test.jar, other-*.jar")()
+
+			copyStub("stub-executable.jar", "stub-executable.jar")
+			copyStub("stub-spring-boot.jar", "stub-test.jar")
+			copyStub("stub-spring-boot.jar", "other-application.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-executable.jar")))
+		})
+
+		it("includes classifier artifacts negated by $BP_BUILT_ARTIFACT_EXCLUDE", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT_EXCLUDE", "!*-plain.jar")()
+
+			copyStub("stub-executable.jar", "stub-1.0.0-plain.jar")
+			copyStub("stub-executable.jar", "stub-1.0.0-sources.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-1.0.0-plain.jar")))
+		})
+
+		it("rejects classifier artifacts next to the artifact they classify", func() {
+			copyStub("stub-spring-boot.jar", "stub-plain.jar")
+			copyStub("stub-executable.jar", "stub.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub.jar")))
+		})
+
+		it("does not reject unversioned artifacts named like classifier artifacts", func() {
+			copyStub("stub-application.jar", "event-1.0.0-sources.jar")
+			copyStub("stub-executable.jar", "event-sources.jar")
+
+			g.Expect(get("*.[jw]ar")).To(gomega.Equal(filepath.Join(f.Build.Application.Root, "event-sources.jar")))
+		})
+
+		it("finds artifacts matching $BP_BUILT_ARTIFACT patterns", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "**/build/libs/*.jar, !api/**")()
+
+			copyStub("stub-executable.jar", filepath.Join("api", "build", "libs", "api.jar"))
+			copyStub("stub-executable.jar", filepath.Join("application", "build", "libs", "application.jar"))
+			copyStub("stub-executable.jar", filepath.Join("build", "other", "other.jar"))
+
+			g.Expect(get("build", "libs", "*.[jw]ar")).
+				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "application", "build", "libs", "application.jar")))
+		})
+
+		it("lists candidates matching $BP_BUILT_ARTIFACT patterns", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "*/target/*.jar")()
+
+			copyStub("stub-executable.jar", filepath.Join("api", "target", "api.jar"))
+			copyStub("stub-executable.jar", filepath.Join("application", "target", "application.jar"))
+
+			_, err := get("target", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.MatchError(
+				fmt.Sprintf("unable to find built artifact (executable JAR or WAR) in */target/*.jar, candidates: [%s %s]",
+					filepath.Join(f.Build.Application.Root, "api", "target", "api.jar"),
+					filepath.Join(f.Build.Application.Root, "application", "target", "application.jar"))))
+		})
+
+		it("fails with invalid $BP_BUILT_ARTIFACT", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "target/*.jar target/[a")()
+
+			_, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.MatchError(
+				"invalid $BP_BUILT_ARTIFACT: invalid pattern target/[a: unterminated character class"))
+		})
+
+		it("fails with $BP_BUILT_ARTIFACT outside the application root", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "../*.jar")()
+
+			_, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.MatchError(
+				"invalid $BP_BUILT_ARTIFACT: ../*.jar is not within the application root"))
+		})
+
+		it("fails with only exclusions in $BP_BUILT_ARTIFACT", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "!*.jar")()
+
+			_, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.MatchError("invalid $BP_BUILT_ARTIFACT: !*.jar contains only exclusions"))
+		})
+
+		it("fails with invalid $BP_BUILT_ARTIFACT_EXCLUDE", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT_EXCLUDE", "[a")()
+
+			_, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.MatchError(
+				"invalid $BP_BUILT_ARTIFACT_EXCLUDE: invalid pattern [a: unterminated character class"))
+		})
+
+		it("selects from reported artifacts", func() {
+			copyStub("stub-spring-boot.jar", filepath.Join("build", "custom", "stub.jar"))
+			copyStub("stub-executable.jar", filepath.Join("build", "custom", "stub-plain.jar"))
+			copyStub("stub-executable.jar", filepath.Join("build", "libs", "stub-executable.jar"))
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "archives.txt"), "%s\n%s\n%s\n",
+				filepath.Join(f.Build.Application.Root, "build", "custom", "stub.jar"),
+				filepath.Join(f.Build.Application.Root, "build", "custom", "stub-plain.jar"),
+				filepath.Join(f.Build.Application.Root, "build", "custom", "missing.jar"))
+
+			b, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "build", "libs", "*.[jw]ar")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(b.Reported(filepath.Join(f.Build.Application.Root, "archives.txt")).Get(f.Build.Application)).
+				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "build", "custom", "stub.jar")))
+		})
+
+		it("searches if no artifacts are reported", func() {
+			copyStub("stub-executable.jar", filepath.Join("build", "libs", "stub-executable.jar"))
+
+			b, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "", "build", "libs", "*.[jw]ar")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(b.Reported(filepath.Join(f.Build.Application.Root, "archives.txt")).Get(f.Build.Application)).
+				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "build", "libs", "stub-executable.jar")))
+		})
+
+		it("rejects reported artifacts outside module", func() {
+			copyStub("stub-executable.jar", filepath.Join("api", "build", "libs", "api.jar"))
+			copyStub("stub-executable.jar", filepath.Join("other", "build", "libs", "other.jar"))
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "archives.txt"), "%s\n%s\n",
+				filepath.Join(f.Build.Application.Root, "api", "build", "libs", "api.jar"),
+				filepath.Join(f.Build.Application.Root, "other", "build", "libs", "other.jar"))
+
+			b, err := runner.NewBuiltArtifactProvider(f.Build.Logger, "api", "build", "libs", "*.[jw]ar")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(b.Reported(filepath.Join(f.Build.Application.Root, "archives.txt")).Get(f.Build.Application)).
+				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "api", "build", "libs", "api.jar")))
+		})
+
+		it("accepts directory matching $BP_BUILT_ARTIFACT", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "target/quarkus-app")()
+
+			copyStub("stub-executable.jar", filepath.Join("target", "quarkus-app", "quarkus-run.jar"))
+
+			g.Expect(get("target", "*.[jw]ar")).
+				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "quarkus-app")))
+		})
+
+		it("prefers distribution to directory and JAR that is not executable", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "build/libs/*.jar build/distributions/* build/install/*")()
+
+			copyStub("stub-application.jar", filepath.Join("build", "libs", "stub-application.jar"))
+			copyStub("stub-distribution.tar.gz", filepath.Join("build", "distributions", "stub-distribution.tar.gz"))
+			copyStub("stub-application.jar", filepath.Join("build", "install", "stub", "lib", "stub-application.jar"))
+
+			g.Expect(get("build", "libs", "*.[jw]ar")).To(gomega.Equal(
+				filepath.Join(f.Build.Application.Root, "build", "distributions", "stub-distribution.tar.gz")))
+		})
+
+		it("fails with multiple distributions", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "build/distributions/*")()
+
+			copyStub("stub-distribution.tar.gz", filepath.Join("build", "distributions", "stub-distribution.tar.gz"))
+			copyStub("stub-distribution.zip", filepath.Join("build", "distributions", "stub-distribution.zip"))
+
+			_, err := get("build", "libs", "*.[jw]ar")
+
+			g.Expect(err).To(gomega.HaveOccurred())
+		})
+
+		it("rejects candidates that cannot be read as archives", func() {
+			defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "build/libs/*.jar build/distributions/*")()
+
+			copyStub("stub-executable.jar", filepath.Join("build", "libs", "stub-executable.jar"))
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "libs", "stub-invalid.jar"), "test")
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "distributions", "stub-invalid.tar.gz"),
+				"test")
+
+			g.Expect(get("build", "libs", "*.[jw]ar")).To(gomega.Equal(
+				filepath.Join(f.Build.Application.Root, "build", "libs", "stub-executable.jar")))
+		})
+
+		when("Maven", func() {
+
+			getMaven := func(module string) (string, error) {
+				b, err := runner.NewMavenBuiltArtifactProvider(f.Build.Logger, module)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				return b.Get(f.Build.Application)
+			}
+
+			it("resolves artifact from pom.xml", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+  <properties>
+    <output>out</output>
+  </properties>
+  <build>
+    <directory>${project.basedir}/${output}</directory>
+    <finalName>${project.artifactId}-application</finalName>
+  </build>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("out", "test-artifact-application.jar"))
+				copyStub("stub-executable.jar", filepath.Join("target", "stub-executable.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "out", "test-artifact-application.jar")))
+			})
+
+			it("resolves artifact inheriting from parent pom.xml", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <groupId>test-group</groupId>
+  <artifactId>test-parent</artifactId>
+  <version>${revision}</version>
+  <packaging>pom</packaging>
+  <properties>
+    <revision>1.0.0</revision>
+  </properties>
+  <build>
+    <finalName>${project.artifactId}-${project.version}-final</finalName>
+    <plugins>
+      <plugin>
+        <artifactId>spring-boot-maven-plugin</artifactId>
+        <configuration>
+          <classifier>exec</classifier>
+        </configuration>
+      </plugin>
+    </plugins>
+  </build>
+</project>`)
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "test-module", "pom.xml"), `<project>
+  <parent>
+    <groupId>test-group</groupId>
+    <artifactId>test-parent</artifactId>
+    <version>${revision}</version>
+  </parent>
+  <artifactId>test-artifact</artifactId>
+  <packaging>war</packaging>
+</project>`)
+				copyStub("stub-application.war",
+					filepath.Join("test-module", "target", "test-artifact-1.0.0-final-exec.war"))
+
+				g.Expect(getMaven("test-module")).To(gomega.Equal(filepath.Join(f.Build.Application.Root,
+					"test-module", "target", "test-artifact-1.0.0-final-exec.war")))
+			})
+
+			it("falls back to target/*.[jw]ar if artifact cannot be resolved", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>${unknown}</version>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("target", "stub-executable.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "stub-executable.jar")))
+			})
+
+			it("falls back to target/*.[jw]ar if artifact does not exist", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("target", "stub-executable.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "stub-executable.jar")))
+			})
+
+			it("resolves artifact with classifier configured in an execution", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+  <build>
+    <plugins>
+      <plugin>
+        <artifactId>spring-boot-maven-plugin</artifactId>
+        <executions>
+          <execution>
+            <goals>
+              <goal>repackage</goal>
+            </goals>
+            <configuration>
+              <classifier>exec</classifier>
+            </configuration>
+          </execution>
+        </executions>
+      </plugin>
+    </plugins>
+  </build>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("target", "test-artifact-1.0.0.jar"))
+				copyStub("stub-spring-boot.jar", filepath.Join("target", "test-artifact-1.0.0-exec.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "test-artifact-1.0.0-exec.jar")))
+			})
+
+			it("falls back to target/*.[jw]ar if artifact is not executable", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+</project>`)
+				copyStub("stub-application.jar", filepath.Join("target", "test-artifact-1.0.0.jar"))
+				copyStub("stub-executable.jar", filepath.Join("target", "test-artifact-1.0.0-shaded.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "test-artifact-1.0.0-shaded.jar")))
+			})
+
+			it("fails if the only artifact is not executable", func() {
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+</project>`)
+				copyStub("stub-application.jar", filepath.Join("target", "test-artifact-1.0.0.jar"))
+
+				_, err := getMaven("")
+				g.Expect(err).To(gomega.HaveOccurred())
+			})
+
+			it("falls back to target/*.[jw]ar if artifact matches $BP_BUILT_ARTIFACT_EXCLUDE", func() {
+				defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT_EXCLUDE", "target/test-artifact-1.0.0.jar")()
+
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("target", "test-artifact-1.0.0.jar"))
+				copyStub("stub-executable.jar", filepath.Join("target", "test-artifact-1.0.0-all.jar"))
+
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "target", "test-artifact-1.0.0-all.jar")))
+			})
+
+			it("does not resolve artifact if $BP_BUILT_ARTIFACT is set", func() {
+				defer test.ReplaceEnv(t, "BP_BUILT_ARTIFACT", "other/*.jar")()
+
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "pom.xml"), `<project>
+  <artifactId>test-artifact</artifactId>
+  <version>1.0.0</version>
+</project>`)
+				copyStub("stub-executable.jar", filepath.Join("target", "test-artifact-1.0.0.jar"))
+				copyStub("stub-executable.jar", filepath.Join("other", "stub-executable.jar"))
 
-			g.Expect(runner.NewBuiltArtifactProvider("*.[jw]ar").Get(f.Build.Application)).
-				To(gomega.Equal(filepath.Join(f.Build.Application.Root, "stub-application.war")))
+				g.Expect(getMaven("")).
+					To(gomega.Equal(filepath.Join(f.Build.Application.Root, "other", "stub-executable.jar")))
+			})
 		})
 	}, spec.Report(report.Terminal{}))
 }
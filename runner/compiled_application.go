@@ -19,44 +19,86 @@ package runner
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
 	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
 	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
 )
 
 // CompiledApplication represents metadata about a compiled application.
 type CompiledApplication struct {
+	// Arguments are the build arguments used to compile the application.
+	Arguments []string `toml:"arguments,omitempty"`
+
+	// Configuration is a digest of the configuration, such as generated settings, used to compile the application.
+	Configuration string `toml:"configuration,omitempty"`
+
+	// Environment is the environment, such as JVM options, used to compile the application.
+	Environment map[string]string `toml:"environment,omitempty"`
+
+	// Executable is the build system executable used to compile the application, relative to the application root if
+	// it is a wrapper.
+	Executable string `toml:"executable,omitempty"`
+
+	// ExecutableVersion is the version of the build system used to compile the application.
+	ExecutableVersion string `toml:"executable-version,omitempty"`
+
+	// GitRevision is the Git commit of the source files used to compile the application, if they are a clean Git
+	// working tree.  The source tree is empty in that case.
+	GitRevision *GitRevision `toml:"git-revision,omitempty"`
+
 	// JavaVersion is the version of Java used to compile the application.
 	JavaVersion string `toml:"java-version"`
 
-	// Sources is metadata about the source files used to compile the application.
-	Sources Sources `toml:"sources"`
+	// Module is the slash-separated module that was built, if not the whole application.
+	Module string `toml:"module,omitempty"`
+
+	// SourceTree is a fingerprint of the source files used to compile the application.
+	SourceTree SourceTree `toml:"source-tree"`
 }
 
 // Identity makes CompiledApplication satisfy the Identifiable interface.
 func (c CompiledApplication) Identity() (string, string) {
-	return "Compiled Application", fmt.Sprintf("(%d files)", len(c.Sources))
+	if c.GitRevision != nil {
+		return "Compiled Application", fmt.Sprintf("(commit %s)", c.GitRevision)
+	}
+
+	return "Compiled Application", fmt.Sprintf("(%d files)", c.SourceTree.Files)
 }
 
-func NewCompiledApplication(application application.Application, runner runner.Runner, logger logger.Logger) (CompiledApplication, error) {
+// NewCompiledApplication creates a new CompiledApplication instance.  If the application root is a clean Git working
+// tree, the source files are identified by its commit.  Otherwise source files excluded by exclusions are ignored.  The
+// hashes of source files whose size and modification time are unchanged are reused from the source index in index,
+// which is then updated.
+func NewCompiledApplication(application application.Application, exclusions SourceExclusions, index layers.Layer,
+	runner runner.Runner, logger logger.Logger) (CompiledApplication, error) {
+
 	v, err := javaVersion(application, runner)
 	if err != nil {
 		return CompiledApplication{}, err
 	}
 
-	s, err := sources(application, logger)
+	if g, ok := NewGitRevision(application, runner, logger); ok {
+		index.Touch()
+		logger.Body("Using Git commit %s of clean working tree", g)
+		return CompiledApplication{GitRevision: &g, JavaVersion: v}, nil
+	}
+
+	s, m, err := sources(application, exclusions, index, logger)
 	if err != nil {
 		return CompiledApplication{}, err
 	}
+	logger.Body("Fingerprinted %s", m)
 
 	return CompiledApplication{
-		v,
-		s,
+		JavaVersion: v,
+		SourceTree:  NewSourceTree(s),
 	}, nil
 }
 
@@ -77,52 +119,140 @@ func javaVersion(application application.Application, runner runner.Runner) (str
 	}
 }
 
-type result struct {
-	err   error
-	value Source
+// fingerprintWorkers is the maximum number of source files hashed concurrently.
+var fingerprintWorkers = runtime.NumCPU()
+
+type fingerprintJob struct {
+	path string
+	info os.FileInfo
 }
 
-func sources(application application.Application, logger logger.Logger) (Sources, error) {
-	ch := make(chan result)
-	var wg sync.WaitGroup
+type fingerprintResult struct {
+	err    error
+	entry  SourceIndexEntry
+	reused bool
+	value  Source
+}
 
-	if err := filepath.Walk(application.Root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+func sources(application application.Application, exclusions SourceExclusions, index layers.Layer,
+	logger logger.Logger) (Sources, FingerprintMetrics, error) {
+
+	start := time.Now()
+	previous := ReadSourceIndex(index)
+
+	jobs := make(chan fingerprintJob)
+	results := make(chan fingerprintResult)
+	walk := make(chan error, 1)
+
+	go func() {
+		walk <- exclusions.Walk(application.Root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
 
+			jobs <- fingerprintJob{path, info}
+			return nil
+		})
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < fingerprintWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
-			source, err := NewSource(path, info, logger)
-			if err != nil {
-				ch <- result{err: err}
-				return
+			for j := range jobs {
+				results <- fingerprint(application.Root, j, previous, logger)
 			}
-
-			ch <- result{value: source}
 		}()
-
-		return nil
-	}); err != nil {
-		return nil, err
 	}
 
 	go func() {
 		wg.Wait()
-		close(ch)
+		close(results)
 	}()
 
-	var s Sources
-	for r := range ch {
+	var (
+		current = SourceIndex{Written: start.UnixNano()}
+		err     error
+		m       FingerprintMetrics
+		s       Sources
+	)
+
+	for r := range results {
 		if r.err != nil {
-			return Sources{}, r.err
+			if err == nil {
+				err = r.err
+			}
+			continue
 		}
 
 		s = append(s, r.value)
+		m.Files++
+
+		if r.entry.Path != "" {
+			current.Entries = append(current.Entries, r.entry)
+
+			if r.reused {
+				m.Reused++
+			} else {
+				m.Hashed++
+			}
+		}
 	}
+
+	if e := <-walk; e != nil {
+		return nil, FingerprintMetrics{}, e
+	}
+
+	if err != nil {
+		return nil, FingerprintMetrics{}, err
+	}
+
 	sort.Sort(s)
 
-	return s, nil
+	if logger.IsDebugEnabled() {
+		for _, c := range current.changes(previous) {
+			logger.Debug("Changed source: %s", c)
+		}
+	}
+
+	if err := WriteSourceIndex(index, current); err != nil {
+		return nil, FingerprintMetrics{}, err
+	}
+
+	m.Duration = time.Since(start)
+	return s, m, nil
+}
+
+// fingerprint returns the Source of a file, reusing the hash in the previous index if the file is unchanged.
+func fingerprint(root string, job fingerprintJob, previous SourceIndex, logger logger.Logger) fingerprintResult {
+	if !job.info.Mode().IsRegular() {
+		s, err := NewSource(root, job.path, job.info, logger)
+		return fingerprintResult{err: err, value: s}
+	}
+
+	rel, err := relativePath(root, job.path)
+	if err != nil {
+		return fingerprintResult{err: err}
+	}
+
+	entry := SourceIndexEntry{Path: rel, Size: job.info.Size(), ModTime: job.info.ModTime().UnixNano()}
+
+	if h, ok := previous.lookup(rel, job.info); ok {
+		entry.SHA256 = h
+		s := Source{Path: rel, Mode: normalizedMode(job.info), SHA256: h}
+
+		logger.Debug("Source: %s", s)
+		return fingerprintResult{entry: entry, reused: true, value: s}
+	}
+
+	s, err := NewSource(root, job.path, job.info, logger)
+	if err != nil {
+		return fingerprintResult{err: err}
+	}
+
+	entry.SHA256 = s.SHA256
+	return fingerprintResult{entry: entry, value: s}
 }
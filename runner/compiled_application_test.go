@@ -0,0 +1,268 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCompiledApplication(t *testing.T) {
+	spec.Run(t, "CompiledApplication", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			exclusions runner.SourceExclusions
+			f          *test.BuildFactory
+			file       string
+			index      layers.Layer
+			source     func() runner.SourceIndexEntry
+		)
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			f.Runner.Outputs = []string{"test-java-version"}
+
+			file = filepath.Join(f.Build.Application.Root, "test-file")
+			test.WriteFile(t, file, "test-content")
+
+			exclusions = runner.NewSourceExclusions(f.Build.Logger, nil)
+			index = f.Build.Layers.Layer("build-system-source-index")
+
+			source = func() runner.SourceIndexEntry {
+				_, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				for _, e := range runner.ReadSourceIndex(index).Entries {
+					if e.Path == "test-file" {
+						return e
+					}
+				}
+
+				t.Fatalf("%s not fingerprinted", file)
+				return runner.SourceIndexEntry{}
+			}
+		})
+
+		it("hashes source files", func() {
+			g.Expect(source().SHA256).To(gomega.Equal("0a3666a0710c08aa6d0de92ce72beeb5b93124cce1bf3701c9d6cdeb543cb73e"))
+		})
+
+		it("writes source index", func() {
+			info, err := os.Stat(file)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(source()).To(gomega.Equal(runner.SourceIndexEntry{
+				Path:    "test-file",
+				Size:    info.Size(),
+				ModTime: info.ModTime().UnixNano(),
+				SHA256:  "0a3666a0710c08aa6d0de92ce72beeb5b93124cce1bf3701c9d6cdeb543cb73e",
+			}))
+			g.Expect(index).To(test.HaveLayerMetadata(false, true, false))
+			g.Expect(filepath.Join(index.Root, "index.json")).To(gomega.BeARegularFile())
+
+			var m map[string]interface{}
+			g.Expect(index.ReadMetadata(&m)).To(gomega.Succeed())
+			g.Expect(m).To(gomega.Equal(map[string]interface{}{"files": int64(1), "version": int64(1)}))
+		})
+
+		it("summarizes source tree", func() {
+			test.TouchFile(t, f.Build.Application.Root, "test-directory", "test-file")
+
+			c, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(c.SourceTree.Digest).To(gomega.HaveLen(64))
+			g.Expect(c.SourceTree.Directories).To(gomega.Equal(2))
+			g.Expect(c.SourceTree.Files).To(gomega.Equal(2))
+		})
+
+		it("changes digest when a nested file changes", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "test-directory", "test-file"), "test-content-1")
+
+			c1, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "test-directory", "test-file"), "test-content-2")
+
+			f.Runner.Outputs = []string{"test-java-version"}
+			c2, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(c2.SourceTree.Digest).NotTo(gomega.Equal(c1.SourceTree.Digest))
+		})
+
+		it("produces the same digest wherever the application is located", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "test-directory", "test-file"), "test-content")
+
+			c1, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			f2 := test.NewBuildFactory(t)
+			f2.Runner.Outputs = []string{"test-java-version"}
+			test.WriteFile(t, filepath.Join(f2.Build.Application.Root, "test-file"), "test-content")
+			test.WriteFile(t, filepath.Join(f2.Build.Application.Root, "test-directory", "test-file"), "test-content")
+
+			c2, err := runner.NewCompiledApplication(f2.Build.Application, exclusions,
+				f2.Build.Layers.Layer("build-system-source-index"), f2.Build.Runner, f2.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(f2.Build.Application.Root).NotTo(gomega.Equal(f.Build.Application.Root))
+			g.Expect(c2.SourceTree).To(gomega.Equal(c1.SourceTree))
+		})
+
+		it("ignores permissions other than executable bits", func() {
+			digest := func() string {
+				f.Runner.Outputs = []string{"test-java-version"}
+				c, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				return c.SourceTree.Digest
+			}
+
+			g.Expect(os.Chmod(file, 0644)).To(gomega.Succeed())
+			d1 := digest()
+
+			g.Expect(os.Chmod(file, 0664)).To(gomega.Succeed())
+			g.Expect(digest()).To(gomega.Equal(d1))
+
+			g.Expect(os.Chmod(file, 0755)).To(gomega.Succeed())
+			g.Expect(digest()).NotTo(gomega.Equal(d1))
+		})
+
+		it("records symlink targets relative to the symlink", func() {
+			g.Expect(os.Symlink(file, filepath.Join(f.Build.Application.Root, "test-link-1"))).To(gomega.Succeed())
+			g.Expect(os.MkdirAll(filepath.Join(f.Build.Application.Root, "test-directory"), 0755)).To(gomega.Succeed())
+			g.Expect(os.Symlink("../test-file", filepath.Join(f.Build.Application.Root, "test-directory", "test-link-2"))).
+				To(gomega.Succeed())
+
+			source := func(path ...string) runner.Source {
+				file := filepath.Join(append([]string{f.Build.Application.Root}, path...)...)
+
+				info, err := os.Lstat(file)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				s, err := runner.NewSource(f.Build.Application.Root, file, info, f.Build.Logger)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				return s
+			}
+
+			g.Expect(source("test-link-1")).
+				To(gomega.Equal(runner.Source{Path: "test-link-1", Mode: runner.SymlinkMode, Target: "test-file"}))
+			g.Expect(source("test-directory", "test-link-2")).
+				To(gomega.Equal(runner.Source{Path: "test-directory/test-link-2", Mode: runner.SymlinkMode, Target: "../test-file"}))
+		})
+
+		it("uses Git revision of clean working tree", func() {
+			test.TouchFile(t, f.Build.Application.Root, ".git", "HEAD")
+			f.Runner.Outputs = []string{"test-java-version", "", "test-commit\ntest-tree\n"}
+
+			c, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(c.GitRevision).To(gomega.Equal(&runner.GitRevision{Commit: "test-commit", Tree: "test-tree"}))
+			g.Expect(c.SourceTree).To(gomega.BeZero())
+			g.Expect(f.Runner.Commands[1]).To(gomega.Equal(test.Command{
+				Bin:  "git",
+				Dir:  f.Build.Application.Root,
+				Args: []string{"-c", "safe.directory=" + f.Build.Application.Root, "status", "--porcelain"},
+			}))
+		})
+
+		it("fingerprints sources of dirty Git working tree", func() {
+			test.TouchFile(t, f.Build.Application.Root, ".git", "HEAD")
+			f.Runner.Outputs = []string{"test-java-version", " M test-file\n"}
+
+			c, err := runner.NewCompiledApplication(f.Build.Application, exclusions, index, f.Build.Runner, f.Build.Logger)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(c.GitRevision).To(gomega.BeNil())
+			g.Expect(c.SourceTree.Files).To(gomega.Equal(1))
+		})
+
+		it("reuses hash of unchanged source files", func() {
+			modTime := time.Now().Add(-time.Hour)
+			g.Expect(os.Chtimes(file, modTime, modTime)).To(gomega.Succeed())
+
+			g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{
+				Entries: []runner.SourceIndexEntry{
+					{Path: "test-file", Size: 12, ModTime: modTime.UnixNano(), SHA256: "test-sha256"},
+				},
+				Written: time.Now().UnixNano(),
+			})).To(gomega.Succeed())
+
+			g.Expect(source().SHA256).To(gomega.Equal("test-sha256"))
+		})
+
+		it("hashes changed source files", func() {
+			info, err := os.Stat(file)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{
+				Entries: []runner.SourceIndexEntry{
+					{Path: "test-file", Size: info.Size(), ModTime: info.ModTime().Add(-time.Second).UnixNano(),
+						SHA256: "test-sha256"},
+				},
+				Written: time.Now().UnixNano(),
+			})).To(gomega.Succeed())
+
+			g.Expect(source().SHA256).To(gomega.Equal("0a3666a0710c08aa6d0de92ce72beeb5b93124cce1bf3701c9d6cdeb543cb73e"))
+		})
+
+		it("hashes source files changed without changing the modification time after the index was written", func() {
+			modTime := time.Now().Add(-time.Hour)
+			g.Expect(os.Chtimes(file, modTime, modTime)).To(gomega.Succeed())
+
+			g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{
+				Entries: []runner.SourceIndexEntry{
+					{Path: "test-file", Size: 12, ModTime: modTime.UnixNano(), SHA256: "test-sha256"},
+				},
+				Written: modTime.UnixNano(),
+			})).To(gomega.Succeed())
+
+			test.WriteFile(t, file, "test-CONTENT")
+			g.Expect(os.Chtimes(file, modTime, modTime)).To(gomega.Succeed())
+
+			g.Expect(source().SHA256).To(gomega.Equal("0eb57ea03204060c98d4bd4daa8fcd288aeb407358182d5475140f9c244370a2"))
+		})
+
+		it("hashes source files with the modification time normalized by pack", func() {
+			modTime := time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+			g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{
+				Entries: []runner.SourceIndexEntry{
+					{Path: "test-file", Size: 12, ModTime: modTime.UnixNano(), SHA256: "test-sha256"},
+				},
+				Written: time.Now().UnixNano(),
+			})).To(gomega.Succeed())
+
+			g.Expect(os.Chtimes(file, modTime, modTime)).To(gomega.Succeed())
+
+			g.Expect(source().SHA256).To(gomega.Equal("0a3666a0710c08aa6d0de92ce72beeb5b93124cce1bf3701c9d6cdeb543cb73e"))
+		})
+	}, spec.Report(report.Terminal{}))
+}
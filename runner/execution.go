@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Configurer contributes configuration to a single execution of a build system.
+type Configurer interface {
+	// Configure modifies the execution, writing any files it requires to the execution's scratch directory.
+	Configure(execution *Execution) error
+
+	// Digest returns a digest of the configuration so that a change to it causes the application to be rebuilt, or
+	// empty if there is no configuration.  The digest is stored in layer metadata and so must not reveal any
+	// credentials in the configuration.
+	Digest() (string, error)
+}
+
+// Execution represents the arguments and environment of a single execution of a build system.
+type Execution struct {
+	// Arguments are the arguments passed to the build system ahead of the build arguments.
+	Arguments []string
+
+	// Artifacts is a file that the build system writes the paths of the artifacts it builds to, one per line, or empty
+	// if the build system does not report them.
+	Artifacts string
+
+	// Environment is the environment set for the duration of the execution.
+	Environment map[string]string
+
+	// Offline is whether the execution must run without network access.
+	Offline bool
+
+	// Scratch is an ephemeral directory that is removed once the execution completes.
+	Scratch string
+
+	// SystemProperties are the system properties of the JVMs that the build system starts, for build systems such as
+	// Gradle whose daemon does not inherit the environment.
+	SystemProperties map[string]string
+}
+
+// AppendEnvironment appends a space-delimited value to an environment variable, starting from the value in the
+// current process if the execution does not yet set the variable.
+func (e *Execution) AppendEnvironment(name string, value string) {
+	current, ok := e.Environment[name]
+	if !ok {
+		current = os.Getenv(name)
+	}
+
+	e.Environment[name] = strings.TrimSpace(fmt.Sprintf("%s %s", current, value))
+}
+
+// Run sets the environment of the execution, calls f, and then restores the previous environment.
+func (e Execution) Run(f func() error) error {
+	previous := make(map[string]*string, len(e.Environment))
+
+	for k, v := range e.Environment {
+		if p, ok := os.LookupEnv(k); ok {
+			previous[k] = &p
+		} else {
+			previous[k] = nil
+		}
+
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+
+	defer func() {
+		for k, v := range previous {
+			if v == nil {
+				_ = os.Unsetenv(k)
+			} else {
+				_ = os.Setenv(k, *v)
+			}
+		}
+	}()
+
+	return f()
+}
+
+// NewExecution creates a new Execution instance.
+func NewExecution(scratch string) Execution {
+	return Execution{
+		Environment:      make(map[string]string),
+		Scratch:          scratch,
+		SystemProperties: make(map[string]string),
+	}
+}
+
+// digest returns the hex encoded SHA-256 digest of the JSON encoding of values.
+func digest(values ...interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	d := sha256.Sum256(b)
+	return hex.EncodeToString(d[:]), nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
+)
+
+// GitRevision identifies the source files of an application by the Git commit checked out in a clean working tree.
+type GitRevision struct {
+	// Commit is the SHA of the commit.
+	Commit string `toml:"commit"`
+
+	// Tree is the hash of the commit's tree.
+	Tree string `toml:"tree"`
+}
+
+// String makes GitRevision satisfy the Stringer interface.
+func (g GitRevision) String() string {
+	if len(g.Commit) > 7 {
+		return g.Commit[:7]
+	}
+
+	return g.Commit
+}
+
+// NewGitRevision creates a new GitRevision instance.  OK is false if the application root is not a Git working tree,
+// if Git is not available, or if the working tree has any modified or untracked files.
+func NewGitRevision(application application.Application, runner runner.Runner,
+	logger logger.Logger) (GitRevision, bool) {
+
+	if exists, err := helper.FileExists(filepath.Join(application.Root, ".git")); err != nil || !exists {
+		return GitRevision{}, false
+	}
+
+	git := func(args ...string) (string, error) {
+		out, err := runner.RunWithOutput("git", application.Root,
+			append([]string{"-c", fmt.Sprintf("safe.directory=%s", application.Root)}, args...)...)
+		return strings.TrimSpace(string(out)), err
+	}
+
+	if status, err := git("status", "--porcelain"); err != nil {
+		logger.Debug("Unable to determine Git status: %s", err)
+		return GitRevision{}, false
+	} else if status != "" {
+		logger.Debug("Git working tree has changes:\n%s", status)
+		return GitRevision{}, false
+	}
+
+	out, err := git("rev-parse", "HEAD", "HEAD^{tree}")
+	if err != nil {
+		logger.Debug("Unable to determine Git revision: %s", err)
+		return GitRevision{}, false
+	}
+
+	s := strings.Fields(out)
+	if len(s) != 2 {
+		logger.Debug("Unable to determine Git revision: unexpected output %q", out)
+		return GitRevision{}, false
+	}
+
+	return GitRevision{Commit: s[0], Tree: s[1]}, true
+}
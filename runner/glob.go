@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// globRegexp compiles a slash-separated glob pattern to a regular expression matching the whole of a slash-separated
+// path.  In addition to the syntax of path.Match, a ** path segment matches zero or more directories.  Errors do not
+// include the pattern, so that callers can report the pattern as it was configured.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(pattern[i+1:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+
+			class := pattern[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += j + 1
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+
+	r, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// globBase returns the longest leading directory of a slash-separated glob pattern that contains no special
+// characters.
+func globBase(pattern string) string {
+	var base []string
+
+	segments := strings.Split(pattern, "/")
+	for _, s := range segments[:len(segments)-1] {
+		if strings.ContainsAny(s, `*?[\`) {
+			break
+		}
+		base = append(base, s)
+	}
+
+	return path.Join(append([]string{"."}, base...)...)
+}
@@ -18,16 +18,66 @@ package runner
 
 import (
 	"github.com/cloudfoundry/build-system-cnb/buildsystem"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 )
 
 // NewRunner creates a new Gradle Runner instance.
 func NewGradleRunner(build build.Build, buildSystem buildsystem.BuildSystem) (Runner, error) {
-	buildArgumentsProvider, err := NewBuildArgumentsProvider("-x", "test", "build")
+	runTests, err := RunTests()
 	if err != nil {
 		return Runner{}, err
 	}
 
-	builtArtifactProvider := NewBuiltArtifactProvider("build", "libs", "*.[jw]ar")
-	return NewRunner(build, buildSystem.Executable(), buildArgumentsProvider, builtArtifactProvider), nil
+	module, err := BuiltModule()
+	if err != nil {
+		return Runner{}, err
+	}
+
+	var testResults TestResults
+	if runTests {
+		testResults = NewTestResults(build,
+			[]string{"build/test-results"},
+			[]string{"build/test-results", "build/reports", "build/jacoco"})
+	}
+
+	buildArgumentsProvider, err := NewGradleBuildArgumentsProvider(runTests, module)
+	if err != nil {
+		return Runner{}, err
+	}
+
+	builtArtifactProvider, err := NewBuiltArtifactProvider(build.Logger, module, "build", "libs", "*.[jw]ar")
+	if err != nil {
+		return Runner{}, err
+	}
+
+	gradleUserHome, err := NewGradleUserHome(build, buildSystem.Offline())
+	if err != nil {
+		return Runner{}, err
+	}
+
+	trustStore, err := NewTrustStore(build, "GRADLE_OPTS")
+	if err != nil {
+		return Runner{}, err
+	}
+
+	configurers := []Configurer{trustStore, gradleUserHome}
+	if buildSystem.Offline() {
+		configurers = append(configurers, NewOffline("--offline"))
+	}
+	if _, ok := config.Lookup("BP_BUILT_ARTIFACT"); !ok {
+		configurers = append(configurers, NewGradleArchives(build, buildArgumentsProvider.Arguments))
+	}
+
+	outputs := map[string][]string{"build": {"build.gradle", "build.gradle.kts"}}
+	exclusions := NewSourceExclusions(build.Logger, outputs, ".gradle/")
+
+	incremental, err := NewIncrementalState(build, outputs, []string{"libs", "distributions", "install",
+		"test-results", "reports", "jacoco", "*/fileHashes"}, ".gradle")
+	if err != nil {
+		return Runner{}, err
+	}
+
+	return NewRunner(build, buildSystem, module, []string{"GRADLE_OPTS"}, exclusions, buildArgumentsProvider,
+		builtArtifactProvider, testResults, incremental, configurers...)
 }
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// gradleArchivesInitScript writes the path of the archive of each JAR task, including war, bootJar, and bootWar, that
+// ran or was up to date to a file.  Zip, Tar, and Sync tasks are not reported, as the application plugin's distZip and
+// distTar would both be selected; distributions must be selected with $BP_BUILT_ARTIFACT.  Task listeners are not
+// supported by the configuration cache, so nothing is reported when it is requested.  Task.archiveFile replaced
+// Task.archivePath in Gradle 5.1.
+const gradleArchivesInitScript = `def archives = new File(%s)
+
+if (gradle.startParameter.hasProperty('configurationCacheRequested') &&
+    gradle.startParameter.configurationCacheRequested) {
+
+    logger.info('Not reporting archives, the configuration cache is requested')
+    return
+}
+
+archives.text = ''
+
+gradle.taskGraph.afterTask { Task task, TaskState state ->
+    def ran = state.failure == null && (state.didWork || state.upToDate)
+
+    if (task instanceof org.gradle.api.tasks.bundling.Jar && ran) {
+        def archive = task.hasProperty('archiveFile') ? task.archiveFile.get().asFile : task.archivePath
+
+        synchronized (archives) {
+            archives << archive.absolutePath + '\n'
+        }
+    }
+}
+`
+
+// gradleConfigurationCacheProperties are the properties in gradle.properties that enable the configuration cache.
+var gradleConfigurationCacheProperties = []string{
+	"org.gradle.configuration-cache",
+	"org.gradle.unsafe.configuration-cache",
+}
+
+// GradleArchives is a Configurer that adds an init script reporting the archives that Gradle builds, so that the built
+// artifact is selected from them rather than found by searching.
+type GradleArchives struct {
+	application application.Application
+	arguments   []string
+	logger      logger.Logger
+}
+
+// Configure makes GradleArchives satisfy the Configurer interface.  The init script is not added if the build
+// arguments or the project's gradle.properties enable the configuration cache.
+func (g GradleArchives) Configure(execution *Execution) error {
+	if ok, err := g.configurationCache(); err != nil {
+		return err
+	} else if ok {
+		g.logger.Body("Not reporting archives, the Gradle configuration cache is enabled")
+		return nil
+	}
+
+	script := filepath.Join(execution.Scratch, "build-system-archives.gradle")
+	execution.Artifacts = filepath.Join(execution.Scratch, "build-system-archives.txt")
+
+	if err := helper.WriteFile(script, 0644, gradleArchivesInitScript, groovyString(execution.Artifacts)); err != nil {
+		return err
+	}
+
+	execution.Arguments = append(execution.Arguments, "--init-script", script)
+	return nil
+}
+
+// Digest makes GradleArchives satisfy the Configurer interface.  Reporting archives does not change the build.
+func (GradleArchives) Digest() (string, error) {
+	return "", nil
+}
+
+func (g GradleArchives) configurationCache() (bool, error) {
+	enabled := false
+
+	file := filepath.Join(g.application.Root, "gradle.properties")
+	if ok, err := helper.FileExists(file); err != nil {
+		return false, err
+	} else if ok {
+		p, err := propertiesLoader.LoadFile(file)
+		if err != nil {
+			return false, err
+		}
+
+		for _, k := range gradleConfigurationCacheProperties {
+			if v, ok := p.Get(k); ok {
+				if b, err := strconv.ParseBool(v); err == nil && b {
+					enabled = true
+				}
+			}
+		}
+	}
+
+	for _, a := range g.arguments {
+		switch a {
+		case "--configuration-cache":
+			enabled = true
+		case "--no-configuration-cache":
+			enabled = false
+		}
+	}
+
+	return enabled, nil
+}
+
+// NewGradleArchives creates a new GradleArchives instance.  arguments are the build arguments passed to Gradle.
+func NewGradleArchives(build build.Build, arguments []string) GradleArchives {
+	return GradleArchives{build.Application, arguments, build.Logger}
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/magiconair/properties"
+)
+
+// GradleBinding is the type of the service binding that contributes Gradle properties and init scripts.
+const GradleBinding = "gradle"
+
+// GradleUserHome is a Configurer that overlays build-time configuration on the persisted Gradle user home.  The
+// overlay is ephemeral so that configuration such as repository credentials never lands in the cache layer.
+type GradleUserHome struct {
+	// InitScripts are the init scripts, keyed by file name, added to init.d.
+	InitScripts map[string]string
+
+	// Properties are the properties added to gradle.properties.
+	Properties map[string]string
+
+	logger    logger.Logger
+	persisted string
+}
+
+// Configure makes GradleUserHome satisfy the Configurer interface.
+func (g GradleUserHome) Configure(execution *Execution) error {
+	if len(g.InitScripts) == 0 && len(g.Properties) == 0 && len(execution.SystemProperties) == 0 {
+		return nil
+	}
+
+	root := filepath.Join(execution.Scratch, "gradle-user-home")
+	g.logger.Body("Overlaying Gradle user home with %d properties and %d init scripts",
+		len(g.Properties)+len(execution.SystemProperties), len(g.InitScripts))
+
+	if err := g.link(root); err != nil {
+		return err
+	}
+
+	if err := g.writeProperties(filepath.Join(root, "gradle.properties"), execution.SystemProperties); err != nil {
+		return err
+	}
+
+	if err := g.writeInitScripts(filepath.Join(root, "init.d")); err != nil {
+		return err
+	}
+
+	execution.Environment["GRADLE_USER_HOME"] = root
+	return nil
+}
+
+// propertiesLoader loads properties verbatim, as Gradle does not expand references in gradle.properties.
+var propertiesLoader = properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+
+// gradleMirrorInitScript rewrites every remote Maven and Ivy repository, including those used to resolve plugins and
+// build script dependencies and those declared in dependencyResolutionManagement since Gradle 6.8, to a mirror.  If
+// pluginManagement declares no repositories, the mirror is added so that the implicit Gradle Plugin Portal is not used.
+const gradleMirrorInitScript = `def mirror = new URI(%s)
+
+def rewrite = { RepositoryHandler repositories ->
+    repositories.all { repository ->
+        if ((repository instanceof MavenArtifactRepository || repository instanceof IvyArtifactRepository) &&
+            repository.url.scheme != 'file' && repository.url != mirror) {
+
+            logger.info("Rewriting repository ${repository.name} (${repository.url}) to mirror")
+            repository.url = mirror
+        }
+    }
+}
+
+settingsEvaluated { settings ->
+    rewrite(settings.pluginManagement.repositories)
+
+    if (settings.pluginManagement.repositories.isEmpty()) {
+        logger.info('Resolving plugins from mirror')
+        settings.pluginManagement.repositories.maven { repository ->
+            repository.name = 'BuildSystemMirror'
+            repository.url = mirror
+        }
+    }
+
+    if (settings.hasProperty('dependencyResolutionManagement')) {
+        rewrite(settings.dependencyResolutionManagement.repositories)
+    }
+}
+
+allprojects {
+    rewrite(buildscript.repositories)
+    rewrite(repositories)
+}
+`
+
+// groovyString returns s as a single-quoted Groovy string literal.
+func groovyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// persistedDirectories are created in the persisted Gradle user home so that Gradle writes them through the overlay.
+var persistedDirectories = []string{"caches", "jdks", "native", "wrapper"}
+
+// Digest makes GradleUserHome satisfy the Configurer interface.  Persisted configuration in the user's Gradle home,
+// such as gradle.properties and init.d, is included, keyed by its path relative to the Gradle home.
+func (g GradleUserHome) Digest() (string, error) {
+	persisted := make(map[string]string)
+
+	for _, f := range []string{"gradle.properties", "init.d"} {
+		if err := filepath.Walk(filepath.Join(g.persisted, f), func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(g.persisted, path)
+			if err != nil {
+				return err
+			}
+
+			persisted[filepath.ToSlash(rel)] = string(b)
+			return nil
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	if len(g.InitScripts) == 0 && len(g.Properties) == 0 && len(persisted) == 0 {
+		return "", nil
+	}
+
+	return digest(g.InitScripts, g.Properties, persisted)
+}
+
+func (g GradleUserHome) link(root string) error {
+	for _, d := range persistedDirectories {
+		if err := os.MkdirAll(filepath.Join(g.persisted, d), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	cs, err := ioutil.ReadDir(g.persisted)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		if c.Name() == "gradle.properties" || c.Name() == "init.d" {
+			continue
+		}
+
+		g.logger.Debug("Linking %s => %s", filepath.Join(g.persisted, c.Name()), filepath.Join(root, c.Name()))
+		if err := os.Symlink(filepath.Join(g.persisted, c.Name()), filepath.Join(root, c.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g GradleUserHome) writeInitScripts(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	persisted := filepath.Join(g.persisted, "init.d")
+	if exists, err := helper.FileExists(persisted); err != nil {
+		return err
+	} else if exists {
+		if err := helper.CopyDirectory(persisted, root); err != nil {
+			return err
+		}
+	}
+
+	for name, script := range g.InitScripts {
+		g.logger.Debug("Writing init script %s", name)
+		if err := helper.WriteFile(filepath.Join(root, name), 0644, "%s", script); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeProperties writes the persisted properties, the binding properties, and the system properties of the execution,
+// which are passed to the Gradle daemon as systemProp.<name> properties.
+func (g GradleUserHome) writeProperties(file string, systemProperties map[string]string) error {
+	p := properties.NewProperties()
+	p.DisableExpansion = true
+
+	persisted := filepath.Join(g.persisted, "gradle.properties")
+	if exists, err := helper.FileExists(persisted); err != nil {
+		return err
+	} else if exists {
+		if p, err = propertiesLoader.LoadFile(persisted); err != nil {
+			return err
+		}
+	}
+
+	var keys []string
+	for k := range g.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		g.logger.Debug("Setting Gradle property %s", k)
+		if _, _, err := p.Set(k, g.Properties[k]); err != nil {
+			return err
+		}
+	}
+
+	keys = nil
+	for k := range systemProperties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		g.logger.Debug("Setting Gradle system property %s", k)
+		if _, _, err := p.Set("systemProp."+k, systemProperties[k]); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = p.Write(f, properties.UTF8)
+	return err
+}
+
+// NewGradleUserHome creates a new GradleUserHome instance, populated from the gradle service binding if one exists.
+// Keys named gradle.properties contribute properties and keys ending in .gradle or .gradle.kts contribute init scripts.
+// If $BP_GRADLE_MIRROR_URL is set, an init script rewriting all remote repositories to the mirror is added.  The
+// proxies configured by the environment are added as system properties.  If offline, the proxies refuse all
+// connections instead.
+func NewGradleUserHome(build build.Build, offline bool) (GradleUserHome, error) {
+	persisted, ok := os.LookupEnv("GRADLE_USER_HOME")
+	if !ok {
+		u, err := user.Current()
+		if err != nil {
+			return GradleUserHome{}, err
+		}
+
+		persisted = filepath.Join(u.HomeDir, ".gradle")
+	}
+
+	g := GradleUserHome{
+		InitScripts: make(map[string]string),
+		Properties:  make(map[string]string),
+		logger:      build.Logger,
+		persisted:   persisted,
+	}
+
+	if mirror, ok, err := config.AbsoluteURL("BP_GRADLE_MIRROR_URL"); err != nil {
+		return GradleUserHome{}, err
+	} else if ok {
+		build.Logger.Body("Using Gradle mirror %s", config.RedactURL(mirror))
+		g.InitScripts["build-system-mirror.gradle"] = fmt.Sprintf(gradleMirrorInitScript, groovyString(mirror))
+	}
+
+	proxies, err := NewProxies()
+	if err != nil {
+		return GradleUserHome{}, err
+	}
+
+	if offline {
+		proxies = offlineProxies
+	}
+
+	for _, p := range proxies {
+		build.Logger.Body("Using %s proxy %s", p.Protocol, p)
+
+		g.Properties[fmt.Sprintf("systemProp.%s.proxyHost", p.Protocol)] = p.Host
+		g.Properties[fmt.Sprintf("systemProp.%s.proxyPort", p.Protocol)] = p.Port
+		if p.Username != "" {
+			g.Properties[fmt.Sprintf("systemProp.%s.proxyUser", p.Protocol)] = p.Username
+		}
+		if p.Password != "" {
+			g.Properties[fmt.Sprintf("systemProp.%s.proxyPassword", p.Protocol)] = p.Password
+		}
+		if len(p.NonProxyHosts) > 0 {
+			// Java reads the non-proxy hosts for both protocols from http.nonProxyHosts
+			g.Properties["systemProp.http.nonProxyHosts"] = strings.Join(p.NonProxyHosts, "|")
+		}
+	}
+
+	c, ok := build.Services.FindServiceCredentials(GradleBinding)
+	if !ok {
+		return g, nil
+	}
+
+	for k, v := range c {
+		s, ok := v.(string)
+		if !ok {
+			return GradleUserHome{}, fmt.Errorf("%s binding key %s is not a string", GradleBinding, k)
+		}
+
+		switch {
+		case k == "gradle.properties":
+			p, err := propertiesLoader.LoadBytes([]byte(s))
+			if err != nil {
+				return GradleUserHome{}, fmt.Errorf("unable to parse %s binding gradle.properties: %w", GradleBinding, err)
+			}
+
+			for _, k := range p.Keys() {
+				g.Properties[k] = p.GetString(k, "")
+			}
+		case strings.HasSuffix(k, ".gradle") || strings.HasSuffix(k, ".gradle.kts"):
+			g.InitScripts[k] = s
+		default:
+			build.Logger.Debug("Ignoring %s binding key %s", GradleBinding, k)
+		}
+	}
+
+	return g, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestGradleUserHome(t *testing.T) {
+	spec.Run(t, "GradleUserHome", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+		})
+
+		digest := func(home string) string {
+			defer test.ReplaceEnv(t, "GRADLE_USER_HOME", home)()
+
+			u, err := runner.NewGradleUserHome(f.Build, false)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			d, err := u.Digest()
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			return d
+		}
+
+		persist := func(home string, properties string) {
+			test.WriteFile(t, filepath.Join(home, "gradle.properties"), properties)
+			test.WriteFile(t, filepath.Join(home, "init.d", "test.gradle"), "test-init-script")
+		}
+
+		it("does not depend on the location of the Gradle user home", func() {
+			persist(filepath.Join(f.Home, "home-1"), "test-key=test-value")
+			persist(filepath.Join(f.Home, "home-2"), "test-key=test-value")
+
+			g.Expect(digest(filepath.Join(f.Home, "home-1"))).NotTo(gomega.BeEmpty())
+			g.Expect(digest(filepath.Join(f.Home, "home-1"))).To(gomega.Equal(digest(filepath.Join(f.Home, "home-2"))))
+		})
+
+		it("depends on the persisted configuration", func() {
+			persist(filepath.Join(f.Home, "home-1"), "test-key=test-value-1")
+			persist(filepath.Join(f.Home, "home-2"), "test-key=test-value-2")
+
+			g.Expect(digest(filepath.Join(f.Home, "home-1"))).NotTo(gomega.Equal(digest(filepath.Join(f.Home, "home-2"))))
+		})
+	}, spec.Report(report.Terminal{}))
+}
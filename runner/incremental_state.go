@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// IncrementalState persists the build output directories of an application in a cache layer so that incremental
+// compilation can reuse them in a later build.  Built artifacts and reports are not persisted, so that a build that no
+// longer produces them does not find stale copies.  The source index of the build is persisted with them, so that the
+// sources changed since can be found when they are restored.  The zero value persists nothing.
+type IncrementalState struct {
+	directories []string
+	exclusions  []string
+	layer       layers.Layer
+	logger      logger.Logger
+	outputs     map[string][]string
+	sourceIndex layers.Layer
+}
+
+// incrementalSourceIndex is the name of the copy of the source index in the incremental state layer.
+const incrementalSourceIndex = "build-system-source-index.json"
+
+// IncrementalDirectories are the directories persisted by IncrementalState.
+type IncrementalDirectories struct {
+	// Directories are the slash-separated paths of the directories relative to the application root.
+	Directories []string `toml:"directories"`
+}
+
+// Identity makes IncrementalDirectories satisfy the Identifiable interface.
+func (i IncrementalDirectories) Identity() (string, string) {
+	return "Incremental Build State", fmt.Sprintf("(%d directories)", len(i.Directories))
+}
+
+// Restore copies the persisted directories into the application root.  Directories that already exist in the
+// application root are not replaced.  If any directory is restored, the sources changed since it was saved are touched.
+func (i IncrementalState) Restore(application application.Application) error {
+	if i.layer.Root == "" {
+		return nil
+	}
+
+	var d IncrementalDirectories
+	if err := i.layer.ReadMetadata(&d); err != nil {
+		return err
+	}
+
+	var restored []string
+	for _, rel := range d.Directories {
+		destination := filepath.Join(application.Root, filepath.FromSlash(rel))
+
+		if exists, err := helper.FileExists(destination); err != nil {
+			return err
+		} else if exists {
+			i.logger.Debug("Not restoring %s as it already exists", rel)
+			continue
+		}
+
+		if err := copyTreeExcept(filepath.Join(i.layer.Root, filepath.FromSlash(rel)), destination,
+			i.isExcluded); err != nil {
+			return err
+		}
+		restored = append(restored, rel)
+	}
+
+	if len(restored) == 0 {
+		return nil
+	}
+
+	i.logger.Body("Restored incremental build state: %s", strings.Join(restored, ", "))
+	return i.touchChanged(application.Root)
+}
+
+// Save replaces the persisted directories with the build output directories in the application root.
+func (i IncrementalState) Save(application application.Application) error {
+	if i.layer.Root == "" {
+		return nil
+	}
+
+	if err := os.RemoveAll(i.layer.Root); err != nil {
+		return err
+	}
+
+	d, err := i.find(application.Root)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range d.Directories {
+		if err := copyTreeExcept(filepath.Join(application.Root, filepath.FromSlash(rel)),
+			filepath.Join(i.layer.Root, filepath.FromSlash(rel)), i.isExcluded); err != nil {
+			return err
+		}
+	}
+
+	if exists, err := helper.FileExists(sourceIndexFile(i.sourceIndex)); err != nil {
+		return err
+	} else if exists {
+		if err := helper.CopyFile(sourceIndexFile(i.sourceIndex),
+			filepath.Join(i.layer.Root, incrementalSourceIndex)); err != nil {
+			return err
+		}
+	}
+
+	i.logger.Body("Saved incremental build state %s", logger.PrettyIdentity(d))
+	return i.layer.WriteMetadata(d, layers.Cache)
+}
+
+// Touch marks the persisted directories as used so that they are kept when the application is not rebuilt.
+func (i IncrementalState) Touch() {
+	if i.layer.Root != "" {
+		i.layer.Touch()
+	}
+}
+
+// touchChanged sets the modification time of each source whose content changed since the state was saved to now.  pack
+// gives every application file the same normalized modification time, so otherwise a changed source could appear older
+// than the restored outputs, and have the same size and modification time as when its hash was cached by the build
+// system.  If the source index of the state cannot be read, every source is touched.
+func (i IncrementalState) touchChanged(root string) error {
+	saved, err := readSourceIndexFile(filepath.Join(i.layer.Root, incrementalSourceIndex))
+	if err != nil {
+		i.logger.Debug("Touching all sources, unable to read source index of incremental build state: %s", err)
+	}
+
+	hashes := make(map[string]string, len(saved.Entries))
+	for _, e := range saved.Entries {
+		hashes[e.Path] = e.SHA256
+	}
+
+	now := time.Now()
+	touched := 0
+
+	for _, e := range ReadSourceIndex(i.sourceIndex).Entries {
+		if h, ok := hashes[e.Path]; ok && h == e.SHA256 {
+			continue
+		}
+
+		i.logger.Debug("Touching changed source %s", e.Path)
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(e.Path)), now, now); err != nil {
+			return err
+		}
+		touched++
+	}
+
+	if touched > 0 {
+		i.logger.Body("Touched %d sources changed since the incremental build state was saved", touched)
+	}
+
+	return nil
+}
+
+// isExcluded returns whether a path relative to a persisted directory is a built artifact or report.
+func (i IncrementalState) isExcluded(rel string) bool {
+	for _, e := range i.exclusions {
+		if ok, _ := path.Match(e, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// find returns the build output directories beneath root.
+func (i IncrementalState) find(root string) (IncrementalDirectories, error) {
+	var d IncrementalDirectories
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() || path == root {
+			return nil
+		}
+
+		rel, err := relativePath(root, path)
+		if err != nil {
+			return err
+		}
+
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		output, err := isOutputDirectory(i.outputs, path, info)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range i.directories {
+			output = output || r == rel
+		}
+
+		if output {
+			d.Directories = append(d.Directories, rel)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return d, err
+}
+
+// NewIncrementalState creates a new IncrementalState instance if $BP_INCREMENTAL is set.  Build output directories are
+// those named in outputs that are next to one of their project files, and the root-relative directories.  Paths within
+// them that match one of the exclusions, slash-separated patterns relative to the directory, are not persisted.
+func NewIncrementalState(build build.Build, outputs map[string][]string, exclusions []string,
+	directories ...string) (IncrementalState, error) {
+
+	incremental, err := config.Bool("BP_INCREMENTAL")
+	if err != nil {
+		return IncrementalState{}, err
+	}
+
+	if !incremental {
+		return IncrementalState{}, nil
+	}
+
+	return IncrementalState{
+		directories,
+		exclusions,
+		build.Layers.Layer("build-system-incremental"),
+		build.Logger,
+		outputs,
+		build.Layers.Layer(sourceIndexLayer),
+	}, nil
+}
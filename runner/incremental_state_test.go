@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestIncrementalState(t *testing.T) {
+	spec.Run(t, "IncrementalState", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			exclusions = []string{"libs", "test-results"}
+			f          *test.BuildFactory
+			outputs    = map[string][]string{"build": {"build.gradle"}}
+		)
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+		})
+
+		it("does nothing if not enabled", func() {
+			test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+			test.TouchFile(t, f.Build.Application.Root, "build", "classes", "test.class")
+
+			i, err := runner.NewIncrementalState(f.Build, outputs, exclusions, ".gradle")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+
+			g.Expect(filepath.Join(f.Build.Layers.Root, "build-system-incremental.toml")).NotTo(gomega.BeAnExistingFile())
+		})
+
+		it("fails if $BP_INCREMENTAL is invalid", func() {
+			defer test.ReplaceEnv(t, "BP_INCREMENTAL", "test-value")()
+
+			_, err := runner.NewIncrementalState(f.Build, outputs, exclusions)
+			g.Expect(err).To(gomega.MatchError("invalid $BP_INCREMENTAL: test-value is not a boolean"))
+		})
+
+		when("enabled", func() {
+
+			var i runner.IncrementalState
+
+			it.Before(func() {
+				defer test.ReplaceEnv(t, "BP_INCREMENTAL", "true")()
+
+				var err error
+				i, err = runner.NewIncrementalState(f.Build, outputs, exclusions, ".gradle")
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			})
+
+			it("saves output directories", func() {
+				test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+				test.TouchFile(t, f.Build.Application.Root, "build", "classes", "test.class")
+				test.TouchFile(t, f.Build.Application.Root, ".gradle", "test-version", "test.lock")
+				test.TouchFile(t, f.Build.Application.Root, "src", "build", "test.txt")
+
+				g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("build-system-incremental")
+				g.Expect(layer).To(test.HaveLayerMetadata(false, true, false))
+				g.Expect(filepath.Join(layer.Root, "build", "classes", "test.class")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, ".gradle", "test-version", "test.lock")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "src")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("does not save built artifacts and reports", func() {
+				test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+				test.TouchFile(t, f.Build.Application.Root, "build", "classes", "test.class")
+				test.TouchFile(t, f.Build.Application.Root, "build", "libs", "test.jar")
+				test.TouchFile(t, f.Build.Application.Root, "build", "test-results", "test", "TEST-test.xml")
+
+				g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("build-system-incremental")
+				g.Expect(filepath.Join(layer.Root, "build", "classes", "test.class")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "build", "libs")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(layer.Root, "build", "test-results")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("does not restore built artifacts and reports", func() {
+				layer := f.Build.Layers.Layer("build-system-incremental")
+				test.TouchFile(t, layer.Root, "build", "classes", "test.class")
+				test.TouchFile(t, layer.Root, "build", "libs", "test.jar")
+				g.Expect(layer.WriteMetadata(runner.IncrementalDirectories{Directories: []string{"build"}},
+					layers.Cache)).To(gomega.Succeed())
+
+				g.Expect(i.Restore(f.Build.Application)).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(f.Build.Application.Root, "build", "classes", "test.class")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(f.Build.Application.Root, "build", "libs")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("restores output directories preserving modification times", func() {
+				modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+				test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+				test.TouchFile(t, f.Build.Application.Root, "build", "classes", "test.class")
+				g.Expect(os.Chtimes(filepath.Join(f.Build.Application.Root, "build", "classes", "test.class"),
+					modTime, modTime)).To(gomega.Succeed())
+				g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+				g.Expect(os.RemoveAll(filepath.Join(f.Build.Application.Root, "build"))).To(gomega.Succeed())
+
+				g.Expect(i.Restore(f.Build.Application)).To(gomega.Succeed())
+
+				info, err := os.Stat(filepath.Join(f.Build.Application.Root, "build", "classes", "test.class"))
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(info.ModTime().Equal(modTime)).To(gomega.BeTrue())
+			})
+
+			it("touches sources changed since the state was saved", func() {
+				normalized := time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+				changed := filepath.Join(f.Build.Application.Root, "src", "Changed.java")
+				unchanged := filepath.Join(f.Build.Application.Root, "src", "Unchanged.java")
+				index := f.Build.Layers.Layer("build-system-source-index")
+
+				test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+				test.TouchFile(t, f.Build.Application.Root, "build", "classes", "Changed.class")
+				g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{Entries: []runner.SourceIndexEntry{
+					{Path: "src/Changed.java", Size: 9, ModTime: normalized.UnixNano(), SHA256: "test-sha256-1"},
+					{Path: "src/Unchanged.java", Size: 9, ModTime: normalized.UnixNano(), SHA256: "test-sha256-2"},
+				}})).To(gomega.Succeed())
+				g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+				g.Expect(os.RemoveAll(filepath.Join(f.Build.Application.Root, "build"))).To(gomega.Succeed())
+
+				test.WriteFile(t, changed, "class B{}")
+				test.WriteFile(t, unchanged, "class C{}")
+				g.Expect(os.Chtimes(changed, normalized, normalized)).To(gomega.Succeed())
+				g.Expect(os.Chtimes(unchanged, normalized, normalized)).To(gomega.Succeed())
+				g.Expect(runner.WriteSourceIndex(index, runner.SourceIndex{Entries: []runner.SourceIndexEntry{
+					{Path: "src/Changed.java", Size: 9, ModTime: normalized.UnixNano(), SHA256: "test-sha256-3"},
+					{Path: "src/Unchanged.java", Size: 9, ModTime: normalized.UnixNano(), SHA256: "test-sha256-2"},
+				}})).To(gomega.Succeed())
+
+				start := time.Now().Add(-time.Second)
+				g.Expect(i.Restore(f.Build.Application)).To(gomega.Succeed())
+
+				info, err := os.Stat(changed)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(info.ModTime().After(start)).To(gomega.BeTrue())
+
+				info, err = os.Stat(unchanged)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(info.ModTime().Equal(normalized)).To(gomega.BeTrue())
+			})
+
+			it("does not replace existing directories", func() {
+				test.TouchFile(t, f.Build.Application.Root, "build.gradle")
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "test.txt"), "saved")
+				g.Expect(i.Save(f.Build.Application)).To(gomega.Succeed())
+				test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "test.txt"), "current")
+
+				g.Expect(i.Restore(f.Build.Application)).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(f.Build.Application.Root, "build", "test.txt")).To(test.HaveContent("current"))
+			})
+
+			it("restores nothing if nothing was saved", func() {
+				g.Expect(i.Restore(f.Build.Application)).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(f.Build.Application.Root, "build")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("keeps saved state when touched", func() {
+				g.Expect(f.Build.Layers.Layer("build-system-incremental").WriteMetadata(
+					runner.IncrementalDirectories{}, layers.Cache)).To(gomega.Succeed())
+
+				i.Touch()
+
+				g.Expect(f.Build.Layers.TouchedLayers.Cleanup()).To(gomega.Succeed())
+				g.Expect(filepath.Join(f.Build.Layers.Root, "build-system-incremental.toml")).To(gomega.BeARegularFile())
+			})
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -17,18 +17,104 @@
 package runner
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/cloudfoundry/build-system-cnb/buildsystem"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 )
 
 // NewRunner creates a new Maven Runner instance.
 func NewMavenRunner(build build.Build, buildSystem buildsystem.BuildSystem) (Runner, error) {
-	buildArgumentsProvider, err := NewBuildArgumentsProvider("-Dmaven.test.skip=true", "package")
+	runTests, err := RunTests()
+	if err != nil {
+		return Runner{}, err
+	}
+
+	module, selected, err := selectMavenModule(build)
 	if err != nil {
 		return Runner{}, err
 	}
 
-	builtArtifactProvider := NewBuiltArtifactProvider("target", "*.[jw]ar")
+	project := ""
+	if selected {
+		project = module
+	}
 
-	return NewRunner(build, buildSystem.Executable(), buildArgumentsProvider, builtArtifactProvider), nil
+	var testResults TestResults
+	if runTests {
+		testResults = NewTestResults(build,
+			[]string{"target/surefire-reports", "target/failsafe-reports"},
+			[]string{"target/surefire-reports", "target/failsafe-reports", "target/site/jacoco", "target/jacoco.exec"})
+	}
+
+	buildArgumentsProvider, err := NewMavenBuildArgumentsProvider(runTests, project)
+	if err != nil {
+		return Runner{}, err
+	}
+
+	builtArtifactProvider, err := NewMavenBuiltArtifactProvider(build.Logger, module)
+	if err != nil {
+		return Runner{}, err
+	}
+
+	mavenSettings, err := NewMavenSettings(build, buildSystem.Offline())
+	if err != nil {
+		return Runner{}, err
+	}
+
+	trustStore, err := NewTrustStore(build, "MAVEN_OPTS")
+	if err != nil {
+		return Runner{}, err
+	}
+
+	configurers := []Configurer{mavenSettings, trustStore}
+	if buildSystem.Offline() {
+		configurers = append(configurers, NewOffline("--offline"))
+	}
+
+	outputs := map[string][]string{"target": {"pom.xml"}}
+	exclusions := NewSourceExclusions(build.Logger, outputs)
+
+	incremental, err := NewIncrementalState(build, outputs, []string{"*.[jw]ar", "*.zip", "*.tar.gz", "quarkus-app",
+		"surefire-reports", "failsafe-reports", "site", "jacoco.exec"})
+	if err != nil {
+		return Runner{}, err
+	}
+
+	return NewRunner(build, buildSystem, module, []string{"MAVEN_OPTS"}, exclusions, buildArgumentsProvider,
+		builtArtifactProvider, testResults, incremental, configurers...)
+}
+
+// selectMavenModule returns $BP_BUILT_MODULE if it is set.  Otherwise, unless $BP_BUILT_ARTIFACT is set, returns the
+// module of the reactor that builds an application and true, as only a selected module is known to be a reactor member
+// that can be built on its own.  If no module builds an application, or the modules cannot be read, returns empty.  If
+// more than one module builds an application, returns an error listing them.
+func selectMavenModule(build build.Build) (string, bool, error) {
+	if _, ok := config.Lookup("BP_BUILT_MODULE"); ok {
+		module, err := BuiltModule()
+		return module, false, err
+	}
+
+	if _, ok := config.Lookup("BP_BUILT_ARTIFACT"); ok {
+		return "", false, nil
+	}
+
+	modules, err := mavenApplicationModules(build.Application.Root)
+	if err != nil {
+		build.Logger.BodyWarning("Unable to select module from pom.xml: %s", err)
+		return "", false, nil
+	}
+
+	switch len(modules) {
+	case 0:
+		return "", false, nil
+	case 1:
+		build.Logger.Body("Selected module %s, the only module that builds an application", modules[0])
+		return modules[0], true, nil
+	default:
+		return "", false, fmt.Errorf("more than one module builds an application, set $BP_BUILT_MODULE to one of: %s",
+			strings.Join(modules, ", "))
+	}
 }
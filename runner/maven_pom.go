@@ -0,0 +1,439 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+)
+
+// maxPOMAncestors limits the number of parent POMs that are read, guarding against cyclic relative paths.
+const maxPOMAncestors = 16
+
+// pomExtensions are the file extensions of the artifacts of the supported packagings.
+var pomExtensions = map[string]string{"jar": "jar", "war": "war"}
+
+var pomExpression = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// MavenPOM is the subset of a Maven pom.xml used to locate the artifact it builds.
+type MavenPOM struct {
+	// GroupID is the group id of the project.
+	GroupID string `xml:"groupId"`
+
+	// ArtifactID is the artifact id of the project.
+	ArtifactID string `xml:"artifactId"`
+
+	// Version is the version of the project.
+	Version string `xml:"version"`
+
+	// Packaging is the packaging of the project.
+	Packaging string `xml:"packaging"`
+
+	// Parent is the parent of the project, if any.
+	Parent *MavenParent `xml:"parent"`
+
+	// Properties are the properties of the project.
+	Properties MavenProperties `xml:"properties"`
+
+	// Build is the build configuration of the project.
+	Build MavenBuild `xml:"build"`
+
+	// Modules are the paths of the modules aggregated by the project.
+	Modules []string `xml:"modules>module"`
+}
+
+// MavenParent is the parent of a Maven project.
+type MavenParent struct {
+	// GroupID is the group id of the parent.
+	GroupID string `xml:"groupId"`
+
+	// ArtifactID is the artifact id of the parent.
+	ArtifactID string `xml:"artifactId"`
+
+	// Version is the version of the parent.
+	Version string `xml:"version"`
+
+	// RelativePath is the path of the parent's pom.xml.  Nil if not specified and empty if the parent is never
+	// looked up locally.
+	RelativePath *string `xml:"relativePath"`
+}
+
+// MavenBuild is the build configuration of a Maven project.
+type MavenBuild struct {
+	// Directory is the directory that output is written to.
+	Directory string `xml:"directory"`
+
+	// FinalName is the file name, without extension, of the artifact.
+	FinalName string `xml:"finalName"`
+
+	// Plugins are the plugins of the build.
+	Plugins []MavenPlugin `xml:"plugins>plugin"`
+}
+
+// MavenPlugin is a plugin of a Maven build.
+type MavenPlugin struct {
+	// ArtifactID is the artifact id of the plugin.
+	ArtifactID string `xml:"artifactId"`
+
+	// Classifier is the classifier of the artifact the plugin creates, if configured.
+	Classifier string `xml:"configuration>classifier"`
+
+	// ExecutionClassifiers are the classifiers of the artifacts the executions of the plugin create, if configured.
+	ExecutionClassifiers []string `xml:"executions>execution>configuration>classifier"`
+
+	// MainClass is the Main-Class of the manifest of the archive the plugin creates, if configured.
+	MainClass string `xml:"configuration>archive>manifest>mainClass"`
+}
+
+// MavenProperties are the properties of a Maven project.
+type MavenProperties map[string]string
+
+// UnmarshalXML decodes each child element as a property.
+func (m *MavenProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = make(MavenProperties)
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch e := t.(type) {
+		case xml.StartElement:
+			var v string
+			if err := d.DecodeElement(&v, &e); err != nil {
+				return err
+			}
+			(*m)[e.Name.Local] = strings.TrimSpace(v)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// mavenProject is a Maven project and the parent POMs that can be found locally, with its properties.
+type mavenProject struct {
+	directory  string
+	poms       []MavenPOM
+	properties map[string]string
+}
+
+// packaging returns the interpolated packaging of the project.
+func (m mavenProject) packaging() (string, error) {
+	return interpolate("${project.packaging}", m.properties)
+}
+
+// plugins returns the plugins of the project and its parents, parents first.
+func (m mavenProject) plugins() []MavenPlugin {
+	var plugins []MavenPlugin
+
+	for i := len(m.poms) - 1; i >= 0; i-- {
+		plugins = append(plugins, m.poms[i].Build.Plugins...)
+	}
+
+	return plugins
+}
+
+// readMavenProject reads the Maven project in directory.  Values are inherited from parent POMs that can be found
+// locally.
+func readMavenProject(directory string) (mavenProject, error) {
+	poms, err := readPOMs(filepath.Join(directory, "pom.xml"))
+	if err != nil {
+		return mavenProject{}, err
+	}
+	project := poms[0]
+
+	properties := map[string]string{
+		"project.basedir":         directory,
+		"project.artifactId":      project.ArtifactID,
+		"project.build.directory": "${project.basedir}/target",
+		"project.build.finalName": "${project.artifactId}-${project.version}",
+		"project.groupId":         project.GroupID,
+		"project.packaging":       project.Packaging,
+		"project.version":         project.Version,
+	}
+
+	if project.Parent != nil && project.GroupID == "" {
+		properties["project.groupId"] = project.Parent.GroupID
+	}
+
+	if project.Parent != nil && project.Version == "" {
+		properties["project.version"] = project.Parent.Version
+	}
+
+	if project.Packaging == "" {
+		properties["project.packaging"] = "jar"
+	}
+
+	for i := len(poms) - 1; i >= 0; i-- {
+		p := poms[i]
+
+		for k, v := range p.Properties {
+			properties[k] = v
+		}
+
+		if p.Build.Directory != "" {
+			properties["project.build.directory"] = p.Build.Directory
+		}
+
+		if p.Build.FinalName != "" {
+			properties["project.build.finalName"] = p.Build.FinalName
+		}
+	}
+
+	return mavenProject{directory, poms, properties}, nil
+}
+
+// mavenArtifact returns the path of the artifact built by the Maven project in directory.  Values are inherited from
+// parent POMs that can be found locally and properties are interpolated.  If the artifact cannot be determined,
+// returns an error.
+func mavenArtifact(directory string) (string, error) {
+	project, err := readMavenProject(directory)
+	if err != nil {
+		return "", err
+	}
+
+	packaging, err := project.packaging()
+	if err != nil {
+		return "", err
+	}
+
+	extension, ok := pomExtensions[packaging]
+	if !ok {
+		return "", fmt.Errorf("packaging %s is not supported", packaging)
+	}
+
+	output, err := interpolate("${project.build.directory}", project.properties)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(directory, output)
+	}
+
+	name, err := interpolate("${project.build.finalName}", project.properties)
+	if err != nil {
+		return "", err
+	}
+
+	classifier := ""
+	for _, p := range project.plugins() {
+		if p.ArtifactID != "spring-boot-maven-plugin" {
+			continue
+		}
+
+		for _, c := range append([]string{p.Classifier}, p.ExecutionClassifiers...) {
+			if c != "" {
+				classifier = c
+			}
+		}
+	}
+
+	if classifier != "" {
+		classifier, err = interpolate(classifier, project.properties)
+		if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%s", name, classifier)
+	}
+
+	return filepath.Join(output, fmt.Sprintf("%s.%s", name, extension)), nil
+}
+
+// mavenApplicationModules returns the slash-separated paths, relative to root, of the modules in the reactor of the
+// pom.xml in root that build an application: those with jar or war packaging that use the spring-boot-maven-plugin
+// or configure a Main-Class.  If root does not contain a pom.xml, returns an empty list.
+func mavenApplicationModules(root string) ([]string, error) {
+	if ok, err := helper.FileExists(filepath.Join(root, "pom.xml")); err != nil || !ok {
+		return nil, err
+	}
+
+	var modules []string
+	visited := make(map[string]bool)
+
+	var visit func(module string) error
+	visit = func(module string) error {
+		if visited[module] {
+			return nil
+		}
+		visited[module] = true
+
+		directory := filepath.Join(root, filepath.FromSlash(module))
+		pom, err := readPOM(filepath.Join(directory, "pom.xml"))
+		if err != nil {
+			return err
+		}
+
+		for _, m := range pom.Modules {
+			if err := visit(path.Join(module, filepath.ToSlash(strings.TrimSpace(m)))); err != nil {
+				return err
+			}
+		}
+
+		if module == "." {
+			return nil
+		}
+
+		project, err := readMavenProject(directory)
+		if err != nil {
+			return err
+		}
+
+		if ok, err := project.isApplication(); err != nil {
+			return err
+		} else if ok {
+			modules = append(modules, module)
+		}
+
+		return nil
+	}
+
+	if err := visit("."); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// isApplication returns whether the project has jar or war packaging and uses the spring-boot-maven-plugin or
+// configures a Main-Class.
+func (m mavenProject) isApplication() (bool, error) {
+	packaging, err := m.packaging()
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := pomExtensions[packaging]; !ok {
+		return false, nil
+	}
+
+	for _, p := range m.plugins() {
+		if p.ArtifactID == "spring-boot-maven-plugin" || p.MainClass != "" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// interpolate replaces the expressions in s with the values of properties or environment variables.
+func interpolate(s string, properties map[string]string) (string, error) {
+	for i := 0; i < maxPOMAncestors && strings.Contains(s, "${"); i++ {
+		var err error
+
+		s = pomExpression.ReplaceAllStringFunc(s, func(e string) string {
+			name := e[2 : len(e)-1]
+
+			if strings.HasPrefix(name, "env.") {
+				if v, ok := os.LookupEnv(strings.TrimPrefix(name, "env.")); ok {
+					return v
+				}
+			}
+
+			for _, n := range []string{name, "project." + strings.TrimPrefix(name, "pom.")} {
+				if v, ok := properties[n]; ok && v != "" {
+					return v
+				}
+			}
+
+			if name == "basedir" {
+				return properties["project.basedir"]
+			}
+
+			err = fmt.Errorf("unable to resolve %s", e)
+			return e
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if strings.Contains(s, "${") {
+		return "", fmt.Errorf("unable to resolve %s", s)
+	}
+
+	return s, nil
+}
+
+// readPOMs reads a pom.xml followed by those of its parents that can be found locally.
+func readPOMs(file string) ([]MavenPOM, error) {
+	p, err := readPOM(file)
+	if err != nil {
+		return nil, err
+	}
+	poms := []MavenPOM{p}
+
+	for p.Parent != nil && len(poms) < maxPOMAncestors {
+		relativePath := filepath.Join("..", "pom.xml")
+		if p.Parent.RelativePath != nil {
+			relativePath = filepath.FromSlash(strings.TrimSpace(*p.Parent.RelativePath))
+		}
+		if relativePath == "" {
+			break
+		}
+
+		file = filepath.Join(filepath.Dir(file), relativePath)
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			file = filepath.Join(file, "pom.xml")
+		}
+
+		if ok, err := helper.FileExists(file); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+
+		parent, err := readPOM(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent.ArtifactID != p.Parent.ArtifactID {
+			break
+		}
+
+		poms = append(poms, parent)
+		p = parent
+	}
+
+	return poms, nil
+}
+
+func readPOM(file string) (MavenPOM, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return MavenPOM{}, err
+	}
+
+	var p MavenPOM
+	if err := xml.Unmarshal(b, &p); err != nil {
+		return MavenPOM{}, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// MavenSettings is a Configurer that generates a Maven settings file for the build.  Any existing user settings file
+// is copied and the generated entries are merged into the copy, ahead of the user's own, so that the global settings
+// in $MAVEN_HOME/conf/settings.xml still apply.
+type MavenSettings struct {
+	// Mirrors are the mirrors added to the settings.
+	Mirrors []MavenMirror
+
+	// Proxies are the proxies added to the settings.
+	Proxies []MavenProxy
+
+	// User is the path of the user's settings file.
+	User string
+
+	logger logger.Logger
+}
+
+// MavenMirror is a mirror entry in a Maven settings file.
+type MavenMirror struct {
+	// ID is the id of the mirror.
+	ID string `xml:"id"`
+
+	// MirrorOf is the specification of the repositories that are mirrored.
+	MirrorOf string `xml:"mirrorOf"`
+
+	// URL is the url of the mirror.
+	URL string `xml:"url"`
+}
+
+// MavenProxy is a proxy entry in a Maven settings file.
+type MavenProxy struct {
+	// ID is the id of the proxy.
+	ID string `xml:"id"`
+
+	// Active is whether the proxy is active.
+	Active bool `xml:"active"`
+
+	// Protocol is the protocol of the requests that are proxied.
+	Protocol string `xml:"protocol"`
+
+	// Host is the host of the proxy.
+	Host string `xml:"host"`
+
+	// Port is the port of the proxy.
+	Port string `xml:"port"`
+
+	// Username is the username used to authenticate with the proxy.
+	Username string `xml:"username,omitempty"`
+
+	// Password is the password used to authenticate with the proxy.
+	Password string `xml:"password,omitempty"`
+
+	// NonProxyHosts is the pipe-separated list of hosts that are not proxied.
+	NonProxyHosts string `xml:"nonProxyHosts,omitempty"`
+}
+
+type mavenSettings struct {
+	XMLName xml.Name      `xml:"settings"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	Proxies []MavenProxy  `xml:"proxies>proxy,omitempty"`
+	Mirrors []MavenMirror `xml:"mirrors>mirror,omitempty"`
+}
+
+// Configure makes MavenSettings satisfy the Configurer interface.
+func (m MavenSettings) Configure(execution *Execution) error {
+	if len(m.Mirrors) == 0 && len(m.Proxies) == 0 {
+		return nil
+	}
+
+	var b []byte
+
+	if exists, err := helper.FileExists(m.User); err != nil {
+		return err
+	} else if exists {
+		m.logger.Debug("Merging generated settings into %s", m.User)
+		user, err := ioutil.ReadFile(m.User)
+		if err != nil {
+			return err
+		}
+
+		if b, err = m.merge(user); err != nil {
+			return fmt.Errorf("unable to merge generated settings into %s: %w", m.User, err)
+		}
+	} else {
+		s, err := xml.MarshalIndent(mavenSettings{
+			XMLNS:   "http://maven.apache.org/SETTINGS/1.0.0",
+			Proxies: m.Proxies,
+			Mirrors: m.Mirrors,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = []byte(fmt.Sprintf("%s%s\n", xml.Header, s))
+	}
+
+	file := filepath.Join(execution.Scratch, "settings.xml")
+	m.logger.Debug("Writing Maven settings to %s", file)
+	if err := helper.WriteFile(file, 0600, "%s", b); err != nil {
+		return err
+	}
+
+	execution.Arguments = append(execution.Arguments, "--settings", file)
+	return nil
+}
+
+// Digest makes MavenSettings satisfy the Configurer interface.  The user's settings are included as Maven applies them
+// to every build.
+func (m MavenSettings) Digest() (string, error) {
+	var user string
+
+	if exists, err := helper.FileExists(m.User); err != nil {
+		return "", err
+	} else if exists {
+		b, err := ioutil.ReadFile(m.User)
+		if err != nil {
+			return "", err
+		}
+		user = string(b)
+	}
+
+	if len(m.Mirrors) == 0 && len(m.Proxies) == 0 && user == "" {
+		return "", nil
+	}
+
+	return digest(m.Mirrors, m.Proxies, user)
+}
+
+// NewMavenSettings creates a new MavenSettings instance, configured with a mirror of all repositories if
+// $BP_MAVEN_MIRROR_URL is set and with the proxies configured by the environment.  If offline, the proxies refuse all
+// connections instead.
+func NewMavenSettings(build build.Build, offline bool) (MavenSettings, error) {
+	u, err := user.Current()
+	if err != nil {
+		return MavenSettings{}, err
+	}
+
+	m := MavenSettings{
+		User:   filepath.Join(u.HomeDir, ".m2", "settings.xml"),
+		logger: build.Logger,
+	}
+
+	if mirror, ok, err := config.AbsoluteURL("BP_MAVEN_MIRROR_URL"); err != nil {
+		return MavenSettings{}, err
+	} else if ok {
+		build.Logger.Body("Using Maven mirror %s", config.RedactURL(mirror))
+		m.Mirrors = append(m.Mirrors, MavenMirror{ID: "build-system-mirror", MirrorOf: "*", URL: mirror})
+	}
+
+	proxies, err := NewProxies()
+	if err != nil {
+		return MavenSettings{}, err
+	}
+
+	if offline {
+		proxies = offlineProxies
+	}
+
+	for _, p := range proxies {
+		build.Logger.Body("Using %s proxy %s", p.Protocol, p)
+		m.Proxies = append(m.Proxies, MavenProxy{
+			ID:            fmt.Sprintf("build-system-%s-proxy", p.Protocol),
+			Active:        true,
+			Protocol:      p.Protocol,
+			Host:          p.Host,
+			Port:          p.Port,
+			Username:      p.Username,
+			Password:      p.Password,
+			NonProxyHosts: strings.Join(p.NonProxyHosts, "|"),
+		})
+	}
+
+	return m, nil
+}
+
+// merge inserts the generated proxies and mirrors at the start of the proxies and mirrors of the user's settings,
+// creating those elements if they do not exist.  Maven uses the first active proxy and the first matching mirror, so
+// the generated entries take precedence.  The rest of the user's settings are copied verbatim.
+func (m MavenSettings) merge(user []byte) ([]byte, error) {
+	type edit struct {
+		start   int64
+		end     int64
+		content []byte
+	}
+
+	sections := []struct {
+		name    string
+		entry   string
+		entries interface{}
+		empty   bool
+		found   bool
+	}{
+		{name: "proxies", entry: "proxy", entries: m.Proxies, empty: len(m.Proxies) == 0},
+		{name: "mirrors", entry: "mirror", entries: m.Mirrors, empty: len(m.Mirrors) == 0},
+	}
+
+	var edits []edit
+	d := xml.NewDecoder(bytes.NewReader(user))
+	depth := 0
+
+	for {
+		start := d.InputOffset()
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch e := t.(type) {
+		case xml.StartElement:
+			depth++
+
+			if depth == 1 && e.Name.Local != "settings" {
+				return nil, fmt.Errorf("unexpected root element %s", e.Name.Local)
+			}
+
+			if depth != 2 {
+				continue
+			}
+
+			for i, s := range sections {
+				if e.Name.Local != s.name || s.empty {
+					continue
+				}
+				sections[i].found = true
+
+				entries, err := marshalEntries(s.entry, s.entries)
+				if err != nil {
+					return nil, err
+				}
+
+				end := d.InputOffset()
+				if bytes.HasSuffix(user[:end], []byte("/>")) {
+					edits = append(edits, edit{start, end, wrapEntries(s.name, entries)})
+				} else {
+					edits = append(edits, edit{end, end, entries})
+				}
+			}
+		case xml.EndElement:
+			depth--
+
+			if depth != 0 {
+				continue
+			}
+
+			for _, s := range sections {
+				if s.found || s.empty {
+					continue
+				}
+
+				entries, err := marshalEntries(s.entry, s.entries)
+				if err != nil {
+					return nil, err
+				}
+
+				edits = append(edits, edit{start, start, []byte(fmt.Sprintf("  %s\n", wrapEntries(s.name, entries)))})
+			}
+
+			var b bytes.Buffer
+			var offset int64
+			for _, e := range edits {
+				b.Write(user[offset:e.start])
+				b.Write(e.content)
+				offset = e.end
+			}
+			b.Write(user[offset:])
+
+			return b.Bytes(), nil
+		}
+	}
+}
+
+func marshalEntries(name string, entries interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("\n")
+
+	e := xml.NewEncoder(&b)
+	e.Indent("    ", "  ")
+	if err := e.EncodeElement(entries, xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func wrapEntries(name string, entries []byte) []byte {
+	return []byte(fmt.Sprintf("<%[1]s>%[2]s\n  </%[1]s>", name, entries))
+}
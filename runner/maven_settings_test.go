@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestMavenSettings(t *testing.T) {
+	spec.Run(t, "MavenSettings", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			e runner.Execution
+			f *test.BuildFactory
+			s runner.MavenSettings
+		)
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			e = runner.NewExecution(test.ScratchDir(t, "execution"))
+
+			var err error
+			s, err = runner.NewMavenSettings(f.Build, false)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			s.User = filepath.Join(f.Home, ".m2", "settings.xml")
+			s.Mirrors = []runner.MavenMirror{{ID: "build-system-mirror", MirrorOf: "*", URL: "https://test-mirror"}}
+		})
+
+		settings := func() string {
+			g.Expect(e.Arguments).To(gomega.HaveLen(2))
+			g.Expect(e.Arguments[0]).To(gomega.Equal("--settings"))
+
+			b, err := ioutil.ReadFile(e.Arguments[1])
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			return string(b)
+		}
+
+		it("does not configure without mirrors or proxies", func() {
+			s.Mirrors = nil
+
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+			g.Expect(e.Arguments).To(gomega.BeEmpty())
+		})
+
+		it("generates settings without user settings", func() {
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+			g.Expect(settings()).To(gomega.Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <proxies></proxies>
+  <mirrors>
+    <mirror>
+      <id>build-system-mirror</id>
+      <mirrorOf>*</mirrorOf>
+      <url>https://test-mirror</url>
+    </mirror>
+  </mirrors>
+</settings>
+`))
+		})
+
+		it("merges generated entries ahead of user entries", func() {
+			test.WriteFile(t, s.User, `<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <localRepository>/test-repository</localRepository>
+  <mirrors>
+    <mirror>
+      <id>test-mirror</id>
+    </mirror>
+  </mirrors>
+</settings>
+`)
+
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+			g.Expect(settings()).To(gomega.Equal(`<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <localRepository>/test-repository</localRepository>
+  <mirrors>
+    <mirror>
+      <id>build-system-mirror</id>
+      <mirrorOf>*</mirrorOf>
+      <url>https://test-mirror</url>
+    </mirror>
+    <mirror>
+      <id>test-mirror</id>
+    </mirror>
+  </mirrors>
+</settings>
+`))
+		})
+
+		it("adds missing sections to user settings", func() {
+			s.Proxies = []runner.MavenProxy{{ID: "build-system-http-proxy", Active: true, Protocol: "http",
+				Host: "test-host", Port: "3128"}}
+			test.WriteFile(t, s.User, `<settings>
+  <proxies/>
+</settings>
+`)
+
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+			g.Expect(settings()).To(gomega.Equal(`<settings>
+  <proxies>
+    <proxy>
+      <id>build-system-http-proxy</id>
+      <active>true</active>
+      <protocol>http</protocol>
+      <host>test-host</host>
+      <port>3128</port>
+    </proxy>
+  </proxies>
+  <mirrors>
+    <mirror>
+      <id>build-system-mirror</id>
+      <mirrorOf>*</mirrorOf>
+      <url>https://test-mirror</url>
+    </mirror>
+  </mirrors>
+</settings>
+`))
+		})
+
+		it("fails with invalid user settings", func() {
+			test.WriteFile(t, s.User, "<profiles/>")
+
+			g.Expect(s.Configure(&e)).To(gomega.MatchError(gomega.HavePrefix("unable to merge generated settings")))
+		})
+	}, spec.Report(report.Terminal{}))
+}
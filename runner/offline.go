@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
+)
+
+// offlineProxies are the proxies used by offline builds.  They refuse connections so that any attempt to access the
+// network fails fast.
+var offlineProxies = []Proxy{
+	{Protocol: "http", Host: "127.0.0.1", Port: "9"},
+	{Protocol: "https", Host: "127.0.0.1", Port: "9"},
+}
+
+// missingArtifact matches the messages that Maven and Gradle print when an artifact is missing from their caches
+// during an offline build.
+var missingArtifact = regexp.MustCompile(
+	`(?:the artifact (\S+) has not been downloaded from it before|No cached version of (\S+) available for offline mode)`)
+
+// Offline is a Configurer that runs the build system without network access.
+type Offline struct {
+	argument string
+}
+
+// Configure makes Offline satisfy the Configurer interface.
+func (o Offline) Configure(execution *Execution) error {
+	execution.Arguments = append(execution.Arguments, o.argument)
+	execution.Offline = true
+	return nil
+}
+
+// Digest makes Offline satisfy the Configurer interface.
+func (o Offline) Digest() (string, error) {
+	return digest(o.argument)
+}
+
+// NewOffline creates a new Offline instance that passes argument to the build system.
+func NewOffline(argument string) Offline {
+	return Offline{argument}
+}
+
+// offlineLineLimit is the number of bytes of each line of offline build output that are scanned for missing artifacts.
+const offlineLineLimit = 64 * 1024
+
+// missingArtifacts is a Writer that scans offline build output line by line for artifacts reported as missing from the
+// cache, so that the output can be streamed rather than retained.
+type missingArtifacts struct {
+	artifacts map[string]bool
+	line      []byte
+}
+
+// Write makes missingArtifacts satisfy the Writer interface.
+func (m *missingArtifacts) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			break
+		}
+
+		m.buffer(p[:i])
+		m.scan()
+		p = p[i+1:]
+	}
+
+	m.buffer(p)
+	return n, nil
+}
+
+// error returns an error naming the artifacts that the output reports as missing from the cache.  If no artifacts are
+// reported as missing, the original error is returned.
+func (m *missingArtifacts) error(err error) error {
+	m.scan()
+
+	if len(m.artifacts) == 0 {
+		return err
+	}
+
+	var missing []string
+	for a := range m.artifacts {
+		missing = append(missing, a)
+	}
+	sort.Strings(missing)
+
+	return fmt.Errorf("offline build failed, artifacts not in cache: %s", missing)
+}
+
+func (m *missingArtifacts) buffer(p []byte) {
+	if r := offlineLineLimit - len(m.line); len(p) > r {
+		p = p[:r]
+	}
+
+	m.line = append(m.line, p...)
+}
+
+func (m *missingArtifacts) scan() {
+	for _, s := range missingArtifact.FindAllSubmatch(m.line, -1) {
+		for _, a := range s[1:] {
+			if len(a) > 0 {
+				if m.artifacts == nil {
+					m.artifacts = make(map[string]bool)
+				}
+				m.artifacts[string(a)] = true
+			}
+		}
+	}
+
+	m.line = m.line[:0]
+}
+
+// writerRunner is a runner.Runner that can also send the combined output of a command to a writer as it runs.
+type writerRunner interface {
+	RunWithWriter(writer io.Writer, bin string, dir string, args ...string) error
+}
+
+// commandRunner is a runner.CommandRunner that also satisfies writerRunner.
+type commandRunner struct {
+	runner.CommandRunner
+}
+
+// RunWithWriter makes commandRunner satisfy the writerRunner interface.
+func (commandRunner) RunWithWriter(writer io.Writer, bin string, dir string, args ...string) error {
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	return cmd.Run()
+}
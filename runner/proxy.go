@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry/build-system-cnb/config"
+)
+
+// Proxy is an HTTP proxy configured by $HTTP_PROXY, $HTTPS_PROXY, and $NO_PROXY.
+type Proxy struct {
+	// Protocol is the protocol of the requests that are proxied.
+	Protocol string
+
+	// Host is the host of the proxy.
+	Host string
+
+	// Port is the port of the proxy.
+	Port string
+
+	// Username is the username used to authenticate with the proxy.
+	Username string
+
+	// Password is the password used to authenticate with the proxy.
+	Password string
+
+	// NonProxyHosts are the hosts, in Java's format, that are not proxied.
+	NonProxyHosts []string
+}
+
+// String makes Proxy satisfy the Stringer interface.  The password is redacted.
+func (p Proxy) String() string {
+	u := url.URL{Scheme: p.Protocol, Host: fmt.Sprintf("%s:%s", p.Host, p.Port)}
+
+	if p.Password != "" {
+		u.User = url.UserPassword(p.Username, "xxxxx")
+	} else if p.Username != "" {
+		u.User = url.User(p.Username)
+	}
+
+	return u.String()
+}
+
+// NewProxies creates Proxy instances from $HTTP_PROXY and $HTTPS_PROXY.  The lowercase variants of the environment
+// variables are used if the uppercase ones are not set.
+func NewProxies() ([]Proxy, error) {
+	nonProxyHosts := nonProxyHosts(lookupEnv("NO_PROXY", "no_proxy"))
+
+	var proxies []Proxy
+	for _, protocol := range []string{"http", "https"} {
+		key := strings.ToUpper(protocol) + "_PROXY"
+
+		s := lookupEnv(key, strings.ToLower(key))
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "://") {
+			s = "http://" + s
+		}
+
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $%s: unable to parse URL", key)
+		}
+
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid $%s: %s does not contain a host", key, config.RedactURL(s))
+		}
+
+		p := Proxy{
+			Protocol:      protocol,
+			Host:          u.Hostname(),
+			Port:          u.Port(),
+			NonProxyHosts: nonProxyHosts,
+		}
+
+		if p.Port == "" {
+			if u.Scheme == "https" {
+				p.Port = "443"
+			} else {
+				p.Port = "80"
+			}
+		}
+
+		if u.User != nil {
+			p.Username = u.User.Username()
+			p.Password, _ = u.User.Password()
+		}
+
+		proxies = append(proxies, p)
+	}
+
+	return proxies, nil
+}
+
+func lookupEnv(keys ...string) string {
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// nonProxyHosts converts the comma-separated $NO_PROXY format to Java's format where domain suffixes are expressed as
+// wildcards.
+func nonProxyHosts(s string) []string {
+	var hosts []string
+
+	for _, h := range strings.Split(s, ",") {
+		h = strings.TrimSpace(h)
+
+		switch {
+		case h == "":
+			continue
+		case h == "*":
+			hosts = append(hosts, "*")
+		case strings.HasPrefix(h, "."):
+			hosts = append(hosts, "*"+h)
+		default:
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
@@ -17,14 +17,16 @@
 package runner
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/build-system-cnb/buildsystem"
+	"github.com/cloudfoundry/build-system-cnb/config"
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
-	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
 	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
 	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
@@ -36,36 +38,90 @@ type Runner struct {
 	bin                    string
 	buildArgumentsProvider BuildArgumentsProvider
 	builtArtifactProvider  BuiltArtifactProvider
+	configurers            []Configurer
+	environment            []string
+	exclusions             SourceExclusions
+	incremental            IncrementalState
 	layer                  layers.Layer
 	logger                 logger.Logger
+	module                 string
 	runner                 runner.Runner
+	sourceIndex            layers.Layer
+	testResults            TestResults
+	version                string
 }
 
 // Contributes builds the application from source code, removes the source code, and expands the built artifact to
 // $APPLICATION_ROOT.
 func (r Runner) Contribute() error {
-	c, err := NewCompiledApplication(r.application, r.runner, r.logger)
+	c, err := r.compiledApplication()
 	if err != nil {
 		return err
 	}
 
+	r.migrate()
+	r.incremental.Touch()
+
 	if err := r.layer.Contribute(c, func(layer layers.Layer) error {
 		if err := os.RemoveAll(layer.Root); err != nil {
 			return err
 		}
 
-		layer.Logger.Body("Executing %s %s", r.bin, strings.Join(r.buildArgumentsProvider.Arguments, " "))
-		if err := r.runner.Run(r.bin, r.application.Root, r.buildArgumentsProvider.Arguments...); err != nil {
+		scratch, err := ioutil.TempDir("", "build-system")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(scratch)
+
+		if err := r.incremental.Restore(r.application); err != nil {
+			return err
+		}
+
+		e := NewExecution(scratch)
+		for _, c := range r.configurers {
+			if err := c.Configure(&e); err != nil {
+				return err
+			}
+		}
+
+		arguments := append(e.Arguments, r.buildArgumentsProvider.Arguments...)
+		layer.Logger.Body("Executing %s %s", r.bin, strings.Join(config.RedactArguments(arguments), " "))
+		runErr := e.Run(func() error {
+			if e.Offline {
+				return r.runOffline(arguments)
+			}
+
+			return r.runner.Run(r.bin, r.application.Root, arguments...)
+		})
+
+		if err := r.testResults.Persist(r.application); err != nil {
+			return err
+		}
+
+		if err := r.testResults.Verify(r.application); err != nil {
 			return err
 		}
 
-		artifact, err := r.builtArtifactProvider.Get(r.application)
+		if runErr != nil {
+			return runErr
+		}
+
+		builtArtifactProvider := r.builtArtifactProvider
+		if e.Artifacts != "" {
+			builtArtifactProvider = builtArtifactProvider.Reported(e.Artifacts)
+		}
+
+		artifact, err := builtArtifactProvider.Get(r.application)
 		if err != nil {
 			return err
 		}
 
-		r.logger.Debug("Copying %s to %s", artifact, r.cachedApplication())
-		return helper.CopyFile(artifact, r.cachedApplication())
+		r.logger.Debug("Copying %s to %s", artifact, layer.Root)
+		if err := cacheArtifact(artifact, layer.Root); err != nil {
+			return err
+		}
+
+		return r.incremental.Save(r.application)
 	}, layers.Cache); err != nil {
 		return err
 	}
@@ -81,22 +137,137 @@ func (r Runner) Contribute() error {
 		}
 	}
 
-	r.logger.Debug("Expanding %s to %s", r.cachedApplication(), r.application.Root)
-	return helper.ExtractZip(r.cachedApplication(), r.application.Root, 0)
+	r.logger.Debug("Expanding %s to %s", r.layer.Root, r.application.Root)
+	return expandArtifact(r.layer.Root, r.application.Root)
 }
 
-func (r Runner) cachedApplication() string {
-	return filepath.Join(r.layer.Root, "application.zip")
+// runOffline runs the build system streaming its output, which is scanned so that a failure caused by artifacts missing
+// from the cache can be reported.  Runners that cannot stream output have it collected instead.
+func (r Runner) runOffline(arguments []string) error {
+	m := &missingArtifacts{}
+	w := io.MultiWriter(os.Stdout, m)
+
+	var err error
+	if s, ok := r.runner.(writerRunner); ok {
+		err = s.RunWithWriter(w, r.bin, r.application.Root, arguments...)
+	} else {
+		var out []byte
+		out, err = r.runner.RunWithOutput(r.bin, r.application.Root, arguments...)
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+
+	if err != nil {
+		return m.error(err)
+	}
+
+	return nil
 }
 
-func NewRunner(build build.Build, bin string, buildArgumentsProvider BuildArgumentsProvider, builtArtifactProvider BuiltArtifactProvider) Runner {
+// compiledApplication returns the metadata used to decide whether the application must be rebuilt.  In addition to the
+// sources, it includes everything that is passed to the build system.
+func (r Runner) compiledApplication() (CompiledApplication, error) {
+	c, err := NewCompiledApplication(r.application, r.exclusions, r.sourceIndex, r.runner, r.logger)
+	if err != nil {
+		return CompiledApplication{}, err
+	}
+
+	if len(r.buildArgumentsProvider.Arguments) > 0 {
+		c.Arguments = r.buildArgumentsProvider.Arguments
+	}
+
+	var digests []string
+	for _, c := range r.configurers {
+		if d, err := c.Digest(); err != nil {
+			return CompiledApplication{}, err
+		} else if d != "" {
+			digests = append(digests, d)
+		}
+	}
+
+	if len(digests) > 0 {
+		if c.Configuration, err = digest(digests); err != nil {
+			return CompiledApplication{}, err
+		}
+	}
+
+	for _, k := range r.environment {
+		if v, ok := os.LookupEnv(k); ok {
+			if c.Environment == nil {
+				c.Environment = make(map[string]string)
+			}
+			c.Environment[k] = v
+		}
+	}
+
+	c.Module = r.module
+
+	if c.Executable, err = r.executable(); err != nil {
+		return CompiledApplication{}, err
+	}
+	c.ExecutableVersion = r.version
+
+	return c, nil
+}
+
+// executable returns the build system executable independent of where the application and the layers are: a wrapper
+// relative to the application root and a distribution by its name, as the version identifies it.
+func (r Runner) executable() (string, error) {
+	if isWithin(r.application.Root, r.bin) {
+		return relativePath(r.application.Root, r.bin)
+	}
+
+	return filepath.Base(r.bin), nil
+}
+
+// migrate logs when the layer metadata contains the per-file sources of earlier versions.  They are superseded by the
+// source tree, so the application is rebuilt once.
+func (r Runner) migrate() {
+	var legacy struct {
+		Sources Sources `toml:"sources"`
+	}
+
+	if err := r.layer.ReadMetadata(&legacy); err != nil || len(legacy.Sources) == 0 {
+		return
+	}
+
+	r.logger.Body("Migrating %d per-file source entries to a source tree digest", len(legacy.Sources))
+}
+
+// NewRunner creates a new Runner instance that builds module, or the whole application if module is empty.  Changes to
+// the module, to the environment variables in environment, in addition to $BP_BUILT_ARTIFACT and
+// $BP_BUILT_ARTIFACT_EXCLUDE, and to source files not excluded by exclusions cause the application to be rebuilt.
+func NewRunner(build build.Build, buildSystem buildsystem.BuildSystem, module string, environment []string,
+	exclusions SourceExclusions, buildArgumentsProvider BuildArgumentsProvider,
+	builtArtifactProvider BuiltArtifactProvider, testResults TestResults, incremental IncrementalState,
+	configurers ...Configurer) (Runner, error) {
+
+	version, err := buildSystem.Version()
+	if err != nil {
+		return Runner{}, err
+	}
+
+	var rn runner.Runner = build.Runner
+	if c, ok := rn.(runner.CommandRunner); ok {
+		rn = commandRunner{c}
+	}
+
 	return Runner{
 		build.Application,
-		bin,
+		buildSystem.Executable(),
 		buildArgumentsProvider,
 		builtArtifactProvider,
+		configurers,
+		append([]string{"BP_BUILT_ARTIFACT", "BP_BUILT_ARTIFACT_EXCLUDE"}, environment...),
+		exclusions,
+		incremental,
 		build.Layers.Layer("build-system-application"),
 		build.Logger,
-		build.Runner,
-	}
+		module,
+		rn,
+		build.Layers.Layer(sourceIndexLayer),
+		testResults,
+		version,
+	}, nil
 }
@@ -17,10 +17,13 @@
 package runner_test
 
 import (
+	"fmt"
+	"io"
 	"os/exec"
 
 	"github.com/buildpacks/libbuildpack/v2/application"
 	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
 )
 
 type testExecutor struct {
@@ -41,3 +44,37 @@ func (t *testExecutor) ExecuteWithOutput(application application.Application, cm
 
 	return []byte(output), nil
 }
+
+type callbackRunner struct {
+	*test.Runner
+	Callback func(bin string, dir string, args ...string)
+}
+
+func (c callbackRunner) Run(bin string, dir string, args ...string) error {
+	c.Callback(bin, dir, args...)
+	return c.Runner.Run(bin, dir, args...)
+}
+
+type failingRunner struct {
+	*test.Runner
+	Output string
+}
+
+func (f failingRunner) RunWithOutput(bin string, dir string, args ...string) ([]byte, error) {
+	if bin == "javac" {
+		return f.Runner.RunWithOutput(bin, dir, args...)
+	}
+
+	f.Runner.Commands = append(f.Runner.Commands, test.Command{Bin: bin, Dir: dir, Args: args})
+	return []byte(f.Output), fmt.Errorf("test-error")
+}
+
+func (f failingRunner) RunWithWriter(writer io.Writer, bin string, dir string, args ...string) error {
+	f.Runner.Commands = append(f.Runner.Commands, test.Command{Bin: bin, Dir: dir, Args: args})
+
+	if _, err := io.WriteString(writer, f.Output); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("test-error")
+}
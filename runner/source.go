@@ -21,45 +21,113 @@ import (
 	"encoding/hex"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
 )
 
+// Modes of source files.  Modes are normalized so that permissions that do not affect a build, such as those resulting
+// from a different umask, do not change the fingerprint.
+const (
+	DirectoryMode  = "directory"
+	ExecutableMode = "executable"
+	FileMode       = "file"
+	SymlinkMode    = "symlink"
+)
+
 // Source is metadata about a source file.
 type Source struct {
-	// Path is the path of the source file.
+	// Path is the slash-separated path of the source file relative to the application root.
 	Path string `toml:"path"`
 
-	// Mode is the human-readable mode of the source file.
+	// Mode is the normalized mode of the source file.
 	Mode string `toml:"mode"`
 
 	// SHA256 is the hash of the source file.
 	SHA256 string `toml:"sha256"`
-}
 
-func NewSource(path string, info os.FileInfo, logger logger.Logger) (Source, error) {
-	if info.IsDir() {
-		return Source{
-			Path: path,
-			Mode: info.Mode().String(),
-		}, nil
-	}
+	// Target is the slash-separated target of a symlink.  Absolute targets within the application root are made
+	// relative to the symlink.
+	Target string `toml:"target,omitempty"`
+}
 
-	h, err := hash(path)
+// NewSource creates a new Source instance for the file at path beneath root.
+func NewSource(root string, path string, info os.FileInfo, logger logger.Logger) (Source, error) {
+	rel, err := relativePath(root, path)
 	if err != nil {
-		return Source{}, nil
+		return Source{}, err
 	}
 
-	s := Source{
-		path,
-		info.Mode().String(),
-		h,
+	s := Source{Path: rel, Mode: normalizedMode(info)}
+
+	switch s.Mode {
+	case ExecutableMode, FileMode:
+		if s.SHA256, err = hash(path); err != nil {
+			return Source{}, err
+		}
+	case SymlinkMode:
+		if s.Target, err = symlinkTarget(root, path); err != nil {
+			return Source{}, err
+		}
 	}
 
 	logger.Debug("Source: %s", s)
 	return s, nil
 }
 
+func (s Source) isDir() bool {
+	return s.Mode == DirectoryMode
+}
+
+func normalizedMode(info os.FileInfo) string {
+	m := info.Mode()
+
+	switch {
+	case m.IsDir():
+		return DirectoryMode
+	case m&os.ModeSymlink != 0:
+		return SymlinkMode
+	case m.IsRegular() && m&0111 != 0:
+		return ExecutableMode
+	case m.IsRegular():
+		return FileMode
+	default:
+		return m.Type().String()
+	}
+}
+
+// relativePath returns the slash-separated path of path relative to root.
+func relativePath(root string, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// isWithin returns whether path is root or beneath it.
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func symlinkTarget(root string, path string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(target) && isWithin(root, target) {
+		if target, err = filepath.Rel(filepath.Dir(path), target); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.ToSlash(target), nil
+}
+
 func hash(file string) (string, error) {
 	s := sha256.New()
 
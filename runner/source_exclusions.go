@@ -0,0 +1,243 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// defaultSourceExclusions are the patterns of files that never affect a build.
+var defaultSourceExclusions = []string{".git/", ".idea/", ".vscode/"}
+
+// SourceExclusions determines which files in the application root are excluded from the source fingerprint.  Files
+// are excluded by default patterns, by build output directories, by .dockerignore and .gitignore files, and by
+// $BP_SOURCE_EXCLUDE.  All patterns use .gitignore syntax and, as in Git, the last matching pattern wins.
+type SourceExclusions struct {
+	// Outputs are the names of build output directories, mapped to the files that identify a project alongside them.
+	Outputs map[string][]string
+
+	// Patterns are the default patterns.
+	Patterns []string
+
+	// Configured are the patterns in $BP_SOURCE_EXCLUDE, which take precedence over all others.
+	Configured []string
+
+	logger logger.Logger
+}
+
+type ignorePattern struct {
+	base    string
+	dirOnly bool
+	negate  bool
+	regexp  *regexp.Regexp
+}
+
+// Walk walks the file tree rooted at root in the same way as filepath.Walk, skipping excluded files and directories.
+func (s SourceExclusions) Walk(root string, walkFn filepath.WalkFunc) error {
+	defaults, err := parseIgnorePatterns(s.Patterns, "", false)
+	if err != nil {
+		return err
+	}
+
+	configured, err := parseIgnorePatterns(s.Configured, "", true)
+	if err != nil {
+		return err
+	}
+
+	files := defaults
+	if p, err := readIgnoreFile(filepath.Join(root, ".dockerignore"), "", true); err != nil {
+		return err
+	} else {
+		files = append(files, p...)
+	}
+
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkFn(file, info, err)
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." {
+			excluded, err := isOutputDirectory(s.Outputs, file, info)
+			if err != nil {
+				return err
+			}
+
+			excluded = matchIgnorePatterns(files, rel, info.IsDir(), excluded)
+			excluded = matchIgnorePatterns(configured, rel, info.IsDir(), excluded)
+
+			if excluded {
+				s.logger.Debug("Excluding %s from source fingerprint", rel)
+
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if info.IsDir() {
+			base := rel
+			if base == "." {
+				base = ""
+			}
+
+			p, err := readIgnoreFile(filepath.Join(file, ".gitignore"), base, false)
+			if err != nil {
+				return err
+			}
+			files = append(files, p...)
+		}
+
+		return walkFn(file, info, nil)
+	})
+}
+
+// isOutputDirectory returns whether file is a directory named in outputs that is next to one of its project files.
+func isOutputDirectory(outputs map[string][]string, file string, info os.FileInfo) (bool, error) {
+	if !info.IsDir() {
+		return false, nil
+	}
+
+	markers, ok := outputs[info.Name()]
+	if !ok {
+		return false, nil
+	}
+
+	for _, m := range markers {
+		if exists, err := helper.FileExists(filepath.Join(filepath.Dir(file), m)); err != nil {
+			return false, err
+		} else if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// NewSourceExclusions creates a new SourceExclusions instance with the build system's output directories and default
+// patterns in addition to the common defaults.
+func NewSourceExclusions(logger logger.Logger, outputs map[string][]string, patterns ...string) SourceExclusions {
+	return SourceExclusions{
+		Outputs:    outputs,
+		Patterns:   append(append([]string{}, defaultSourceExclusions...), patterns...),
+		Configured: config.Values("BP_SOURCE_EXCLUDE"),
+		logger:     logger,
+	}
+}
+
+func matchIgnorePatterns(patterns []ignorePattern, rel string, dir bool, excluded bool) bool {
+	for _, p := range patterns {
+		if p.dirOnly && !dir {
+			continue
+		}
+
+		r := rel
+		if p.base != "" {
+			if !strings.HasPrefix(rel, p.base+"/") {
+				continue
+			}
+			r = strings.TrimPrefix(rel, p.base+"/")
+		}
+
+		if p.regexp.MatchString(r) {
+			excluded = !p.negate
+		}
+	}
+
+	return excluded
+}
+
+// parseIgnorePatterns parses patterns in .gitignore syntax relative to base.  If anchored, patterns without a slash
+// match relative to base, as in .dockerignore, rather than at any depth.
+func parseIgnorePatterns(lines []string, base string, anchored bool) ([]ignorePattern, error) {
+	var patterns []ignorePattern
+
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t\r")
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		line := l
+
+		p := ignorePattern{base: base}
+
+		if strings.HasPrefix(l, "!") {
+			p.negate = true
+			l = l[1:]
+		} else if strings.HasPrefix(l, `\!`) || strings.HasPrefix(l, `\#`) {
+			l = l[1:]
+		}
+
+		if strings.HasSuffix(l, "/") {
+			p.dirOnly = true
+			l = strings.TrimSuffix(l, "/")
+		}
+
+		if !anchored && !strings.Contains(l, "/") {
+			l = path.Join("**", l)
+		}
+		l = strings.TrimPrefix(l, "/")
+
+		r, err := globRegexp(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", line, err)
+		}
+		p.regexp = r
+
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+func readIgnoreFile(file string, base string, anchored bool) ([]ignorePattern, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return parseIgnorePatterns(lines, base, anchored)
+}
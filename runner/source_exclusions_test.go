@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSourceExclusions(t *testing.T) {
+	spec.Run(t, "SourceExclusions", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			f    *test.BuildFactory
+			walk func(s runner.SourceExclusions) []string
+		)
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+
+			walk = func(s runner.SourceExclusions) []string {
+				var paths []string
+
+				g.Expect(s.Walk(f.Build.Application.Root, func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+
+					rel, err := filepath.Rel(f.Build.Application.Root, path)
+					if err != nil {
+						return err
+					}
+
+					paths = append(paths, filepath.ToSlash(rel))
+					return nil
+				})).To(gomega.Succeed())
+
+				return paths
+			}
+		})
+
+		it("excludes default patterns", func() {
+			test.TouchFile(t, f.Build.Application.Root, ".git", "HEAD")
+			test.TouchFile(t, f.Build.Application.Root, ".idea", "workspace.xml")
+			test.TouchFile(t, f.Build.Application.Root, "src", "main.java")
+
+			g.Expect(walk(runner.NewSourceExclusions(f.Build.Logger, nil))).
+				To(gomega.Equal([]string{".", "src", "src/main.java"}))
+		})
+
+		it("excludes output directories next to a project file", func() {
+			test.TouchFile(t, f.Build.Application.Root, "pom.xml")
+			test.TouchFile(t, f.Build.Application.Root, "target", "application.jar")
+			test.TouchFile(t, f.Build.Application.Root, "src", "target", "Target.java")
+
+			g.Expect(walk(runner.NewSourceExclusions(f.Build.Logger, map[string][]string{"target": {"pom.xml"}}))).
+				To(gomega.Equal([]string{".", "pom.xml", "src", "src/target", "src/target/Target.java"}))
+		})
+
+		it("honors .gitignore files", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, ".gitignore"), "# comment\n*.log\n!keep.log\n/out/\n")
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "module", ".gitignore"), "generated\n")
+			test.TouchFile(t, f.Build.Application.Root, "test.log")
+			test.TouchFile(t, f.Build.Application.Root, "keep.log")
+			test.TouchFile(t, f.Build.Application.Root, "out", "test-file")
+			test.TouchFile(t, f.Build.Application.Root, "module", "generated", "test-file")
+			test.TouchFile(t, f.Build.Application.Root, "module", "out", "test.log")
+			test.TouchFile(t, f.Build.Application.Root, "generated")
+
+			g.Expect(walk(runner.NewSourceExclusions(f.Build.Logger, nil))).
+				To(gomega.Equal([]string{".", ".gitignore", "generated", "keep.log", "module", "module/.gitignore",
+					"module/out"}))
+		})
+
+		it("honors .dockerignore files", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, ".dockerignore"), "docs\n**/*.md\n")
+			test.TouchFile(t, f.Build.Application.Root, "docs", "index.html")
+			test.TouchFile(t, f.Build.Application.Root, "module", "docs", "README.md")
+
+			g.Expect(walk(runner.NewSourceExclusions(f.Build.Logger, nil))).
+				To(gomega.Equal([]string{".", ".dockerignore", "module", "module/docs"}))
+		})
+
+		it("honors $BP_SOURCE_EXCLUDE", func() {
+			defer test.ReplaceEnv(t, "BP_SOURCE_EXCLUDE", "docs/**, !docs/api, !docs/api/**")()
+
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, ".gitignore"), "!*.md\n")
+			test.TouchFile(t, f.Build.Application.Root, "docs", "README.md")
+			test.TouchFile(t, f.Build.Application.Root, "docs", "api", "index.html")
+
+			g.Expect(walk(runner.NewSourceExclusions(f.Build.Logger, nil))).
+				To(gomega.Equal([]string{".", ".gitignore", "docs", "docs/api", "docs/api/index.html"}))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+)
+
+// SourceIndex records the size and modification time of each source file alongside its hash so that a later build can
+// reuse the hash of a file that has not changed.
+type SourceIndex struct {
+	// Entries are the indexed source files.
+	Entries []SourceIndexEntry `json:"entries"`
+
+	// Written is the time, in nanoseconds since the Unix epoch, at which fingerprinting the indexed files started.
+	Written int64 `json:"written"`
+}
+
+// sourceIndexMetadata is the metadata of the source index layer.  The index itself is written to a file in the layer,
+// as it is too large to be parsed and rewritten as TOML on every build.
+type sourceIndexMetadata struct {
+	// Version is the version of the format of the index file.
+	Version int `toml:"version"`
+
+	// Files is the number of indexed source files.
+	Files int `toml:"files"`
+}
+
+// sourceIndexLayer is the name of the layer containing the source index.
+const sourceIndexLayer = "build-system-source-index"
+
+// sourceIndexVersion is the version of the format of the index file.  An index written in another format is ignored.
+const sourceIndexVersion = 1
+
+// normalizedModTime is the modification time that pack gives every application file, so it does not identify content.
+var normalizedModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC).UnixNano()
+
+// SourceIndexEntry is the index entry of a single source file.
+type SourceIndexEntry struct {
+	// Path is the path of the source file.
+	Path string `json:"path"`
+
+	// Size is the size of the source file in bytes.
+	Size int64 `json:"size"`
+
+	// ModTime is the modification time of the source file in nanoseconds since the Unix epoch.
+	ModTime int64 `json:"mod-time"`
+
+	// SHA256 is the hash of the source file.
+	SHA256 string `json:"sha256"`
+}
+
+// FingerprintMetrics are metrics about fingerprinting the source files of an application.
+type FingerprintMetrics struct {
+	// Duration is how long fingerprinting took.
+	Duration time.Duration
+
+	// Files is the number of files and directories fingerprinted.
+	Files int
+
+	// Hashed is the number of files whose hash was computed.
+	Hashed int
+
+	// Reused is the number of files whose hash was reused from the previous build.
+	Reused int
+}
+
+// String makes FingerprintMetrics satisfy the Stringer interface.
+func (f FingerprintMetrics) String() string {
+	return fmt.Sprintf("%d files in %s (%d hashed, %d reused)",
+		f.Files, f.Duration.Round(time.Millisecond), f.Hashed, f.Reused)
+}
+
+// lookup returns the hash of a file recorded in the index if its size and modification time are unchanged.  A
+// modification time that is normalized, or not older than the index, does not tell a later change apart from the
+// indexed content, so the file is hashed again.
+func (s SourceIndex) lookup(path string, info os.FileInfo) (string, bool) {
+	i := sort.Search(len(s.Entries), func(i int) bool {
+		return s.Entries[i].Path >= path
+	})
+
+	if i == len(s.Entries) || s.Entries[i].Path != path {
+		return "", false
+	}
+
+	e := s.Entries[i]
+	if e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+
+	if e.ModTime == normalizedModTime || e.ModTime >= s.Written {
+		return "", false
+	}
+
+	return e.SHA256, true
+}
+
+// changes returns a description of each file that was added, modified, or removed since the previous index.
+func (s SourceIndex) changes(previous SourceIndex) []string {
+	hashes := make(map[string]string, len(previous.Entries))
+	for _, e := range previous.Entries {
+		hashes[e.Path] = e.SHA256
+	}
+
+	var changes []string
+	for _, e := range s.Entries {
+		if h, ok := hashes[e.Path]; !ok {
+			changes = append(changes, fmt.Sprintf("%s (added)", e.Path))
+		} else if h != e.SHA256 {
+			changes = append(changes, fmt.Sprintf("%s (modified)", e.Path))
+		}
+		delete(hashes, e.Path)
+	}
+
+	for p := range hashes {
+		changes = append(changes, fmt.Sprintf("%s (removed)", p))
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// ReadSourceIndex reads the source index from its layer.  An index that is missing, unreadable, or written in another
+// format is empty, so that every source file is hashed.
+func ReadSourceIndex(layer layers.Layer) SourceIndex {
+	var m sourceIndexMetadata
+	if err := layer.ReadMetadata(&m); err != nil {
+		layer.Logger.Debug("Ignoring unreadable source index: %s", err)
+		return SourceIndex{}
+	} else if m.Version != sourceIndexVersion {
+		return SourceIndex{}
+	}
+
+	s, err := readSourceIndexFile(sourceIndexFile(layer))
+	if err != nil {
+		layer.Logger.Debug("Ignoring unreadable source index: %s", err)
+		return SourceIndex{}
+	}
+
+	return s
+}
+
+// WriteSourceIndex writes the source index to a file in its layer, recording only its version and size in the layer
+// metadata.
+func WriteSourceIndex(layer layers.Layer, index SourceIndex) error {
+	layer.Touch()
+
+	if err := os.MkdirAll(layer.Root, 0755); err != nil {
+		return err
+	}
+
+	if err := writeSourceIndexFile(sourceIndexFile(layer), index); err != nil {
+		return err
+	}
+
+	return layer.WriteMetadata(sourceIndexMetadata{Version: sourceIndexVersion, Files: len(index.Entries)}, layers.Cache)
+}
+
+func sourceIndexFile(layer layers.Layer) string {
+	return filepath.Join(layer.Root, "index.json")
+}
+
+// readSourceIndexFile reads a source index file, sorting its entries by path.
+func readSourceIndexFile(file string) (SourceIndex, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return SourceIndex{}, err
+	}
+	defer f.Close()
+
+	var s SourceIndex
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(&s); err != nil {
+		return SourceIndex{}, err
+	}
+
+	sort.Slice(s.Entries, func(i, j int) bool {
+		return s.Entries[i].Path < s.Entries[j].Path
+	})
+
+	return s, nil
+}
+
+// writeSourceIndexFile writes a source index file, sorting its entries by path.
+func writeSourceIndexFile(file string, index SourceIndex) error {
+	sort.Slice(index.Entries, func(i, j int) bool {
+		return index.Entries[i].Path < index.Entries[j].Path
+	})
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(index); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SourceTree is a compact fingerprint of the source files of an application.  The digest is the root of a Merkle tree
+// in which each file is hashed with its name, mode, and content or symlink target, and each directory with its name,
+// mode, and the hashes of its children, so that any change to any file changes the digest.
+type SourceTree struct {
+	// Digest is the root hash of the tree.
+	Digest string `toml:"digest"`
+
+	// Directories is the number of directories in the tree.
+	Directories int `toml:"directories"`
+
+	// Files is the number of files in the tree.
+	Files int `toml:"files"`
+}
+
+type sourceTreeNode struct {
+	children []string
+	source   Source
+}
+
+// NewSourceTree creates a new SourceTree instance from sources with paths relative to the application root.
+func NewSourceTree(sources Sources) SourceTree {
+	nodes := make(map[string]*sourceTreeNode, len(sources))
+	for _, s := range sources {
+		nodes[s.Path] = &sourceTreeNode{source: s}
+	}
+
+	for _, s := range sources {
+		if s.Path == "." {
+			continue
+		}
+
+		if parent, ok := nodes[path.Dir(s.Path)]; ok {
+			parent.children = append(parent.children, s.Path)
+		}
+	}
+
+	var t SourceTree
+	for _, n := range nodes {
+		if n.source.isDir() {
+			t.Directories++
+		} else {
+			t.Files++
+		}
+	}
+
+	if n, ok := nodes["."]; ok {
+		t.Digest = n.hash(nodes, "")
+	}
+
+	return t
+}
+
+func (n sourceTreeNode) hash(nodes map[string]*sourceTreeNode, name string) string {
+	h := sha256.New()
+
+	if n.source.isDir() {
+		_, _ = h.Write([]byte(strings.Join([]string{"directory", name, n.source.Mode}, "\x00")))
+
+		sort.Strings(n.children)
+		for _, c := range n.children {
+			_, _ = h.Write([]byte("\x00" + nodes[c].hash(nodes, path.Base(c))))
+		}
+	} else {
+		_, _ = h.Write([]byte(strings.Join([]string{"file", name, n.source.Mode, n.source.SHA256, n.source.Target}, "\x00")))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpacks/libbuildpack/v2/application"
+	"github.com/cloudfoundry/build-system-cnb/config"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// TestFailureCode is the exit code of a build that fails because tests failed.
+const TestFailureCode = 104
+
+// TestFailureError is the error returned when tests run during a build fail.
+type TestFailureError struct {
+	// Failures are the names of the failed tests.
+	Failures []string
+}
+
+func (t TestFailureError) Error() string {
+	return fmt.Sprintf("%d tests failed: %s", len(t.Failures), strings.Join(t.Failures, ", "))
+}
+
+// TestResults represents the JUnit XML reports written by the tests run during a build and the test and coverage
+// reports that are persisted to a layer marked build.
+type TestResults struct {
+	directories []string
+	layer       layers.Layer
+	logger      logger.Logger
+	reports     []string
+}
+
+// TestReports is the index of the reports persisted from a build.
+type TestReports struct {
+	// Reports are the paths, relative to the application root, of the persisted reports.
+	Reports []string `toml:"reports"`
+}
+
+// Identity makes TestReports satisfy the Identifiable interface.
+func (t TestReports) Identity() (string, string) {
+	return "Test Reports", fmt.Sprintf("(%d reports)", len(t.Reports))
+}
+
+// TestSummary is a summary of the test results of a build.
+type TestSummary struct {
+	// Failed are the names of the tests that failed or errored.
+	Failed []string
+
+	// Passed is the number of tests that passed.
+	Passed int
+
+	// Skipped is the number of tests that were skipped.
+	Skipped int
+}
+
+// Verify parses the reports written during the build, prints a summary of them, and returns a TestFailureError if any
+// tests failed.  If tests are not run, does nothing.
+func (t TestResults) Verify(application application.Application) error {
+	if len(t.directories) == 0 {
+		return nil
+	}
+
+	s, err := t.Summary(application)
+	if err != nil {
+		return err
+	}
+
+	t.logger.Header("Test results")
+	t.logger.Body("%d passed, %d failed, %d skipped", s.Passed, len(s.Failed), s.Skipped)
+	for _, f := range s.Failed {
+		t.logger.BodyError("Failed: %s", f)
+	}
+
+	if len(s.Failed) > 0 {
+		return TestFailureError{s.Failed}
+	}
+
+	return nil
+}
+
+// Persist copies the test and coverage reports beneath the application root to a layer marked build, recording their
+// paths in the layer's metadata.  If tests are not run, does nothing.
+func (t TestResults) Persist(application application.Application) error {
+	if len(t.reports) == 0 {
+		return nil
+	}
+
+	var reports TestReports
+	if err := filepath.Walk(application.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(application.Root, path)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range t.reports {
+			if s := filepath.ToSlash(rel); s == r || strings.HasSuffix(s, "/"+r) {
+				reports.Reports = append(reports.Reports, s)
+
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(reports.Reports) == 0 {
+		return nil
+	}
+
+	return t.layer.Contribute(reports, func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		for _, r := range reports.Reports {
+			source := filepath.Join(application.Root, filepath.FromSlash(r))
+			destination := filepath.Join(layer.Root, filepath.FromSlash(r))
+
+			layer.Logger.Body("Copying %s", r)
+			if info, err := os.Stat(source); err != nil {
+				return err
+			} else if info.IsDir() {
+				if err := helper.CopyDirectory(source, destination); err != nil {
+					return err
+				}
+			} else if err := helper.CopyFile(source, destination); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, layers.Build)
+}
+
+// Reports returns the paths of the JUnit XML reports beneath the application root.
+func (t TestResults) Reports(application application.Application) ([]string, error) {
+	var reports []string
+
+	if err := filepath.Walk(application.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.HasPrefix(info.Name(), "TEST-") || !strings.HasSuffix(info.Name(), ".xml") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(application.Root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		for _, d := range t.directories {
+			if strings.Contains(fmt.Sprintf("/%s/", filepath.ToSlash(rel)), fmt.Sprintf("/%s/", d)) {
+				reports = append(reports, path)
+				break
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// Summary returns a summary of the JUnit XML reports beneath the application root.
+func (t TestResults) Summary(application application.Application) (TestSummary, error) {
+	reports, err := t.Reports(application)
+	if err != nil {
+		return TestSummary{}, err
+	}
+
+	var s TestSummary
+	for _, r := range reports {
+		t.logger.Debug("Parsing test report %s", r)
+
+		if err := s.add(r); err != nil {
+			return TestSummary{}, err
+		}
+	}
+
+	return s, nil
+}
+
+type testSuite struct {
+	Cases  []testCase  `xml:"testcase"`
+	Suites []testSuite `xml:"testsuite"`
+}
+
+type testCase struct {
+	ClassName string     `xml:"classname,attr"`
+	Name      string     `xml:"name,attr"`
+	Errors    []struct{} `xml:"error"`
+	Failures  []struct{} `xml:"failure"`
+	Skipped   *struct{}  `xml:"skipped"`
+}
+
+func (s *TestSummary) add(report string) error {
+	f, err := os.Open(report)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var suite testSuite
+	if err := xml.NewDecoder(f).Decode(&suite); err != nil {
+		return fmt.Errorf("unable to parse test report %s: %w", report, err)
+	}
+
+	s.addSuite(suite)
+	return nil
+}
+
+func (s *TestSummary) addSuite(suite testSuite) {
+	for _, c := range suite.Cases {
+		switch {
+		case len(c.Errors) > 0 || len(c.Failures) > 0:
+			s.Failed = append(s.Failed, fmt.Sprintf("%s.%s", c.ClassName, c.Name))
+		case c.Skipped != nil:
+			s.Skipped++
+		default:
+			s.Passed++
+		}
+	}
+
+	for _, child := range suite.Suites {
+		s.addSuite(child)
+	}
+}
+
+// RunTests returns whether $BP_RUN_TESTS requests that tests are run during the build.
+func RunTests() (bool, error) {
+	return config.Bool("BP_RUN_TESTS")
+}
+
+// NewTestResults creates a new TestResults instance that parses JUnit XML reports in directories and persists the
+// reports in reports.  Both are expressed as slash-separated paths that may be nested anywhere beneath the application
+// root.
+func NewTestResults(build build.Build, directories []string, reports []string) TestResults {
+	return TestResults{
+		directories,
+		build.Layers.Layer("build-system-reports"),
+		build.Logger,
+		reports,
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestTestResults(t *testing.T) {
+	spec.Run(t, "TestResults", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+		})
+
+		it("does nothing if tests are not run", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "target", "surefire-reports", "TEST-test.xml"), "invalid")
+
+			g.Expect(runner.TestResults{}.Verify(f.Build.Application)).To(gomega.Succeed())
+		})
+
+		it("summarizes reports", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "target", "surefire-reports", "TEST-test.xml"), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="test.TestOne" tests="3" failures="1" errors="0" skipped="1">
+  <testcase classname="test.TestOne" name="passes"/>
+  <testcase classname="test.TestOne" name="fails"><failure message="test-message"/></testcase>
+  <testcase classname="test.TestOne" name="skips"><skipped/></testcase>
+</testsuite>`)
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "test-module", "target", "failsafe-reports", "TEST-test.xml"), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="test.TestTwo">
+    <testcase classname="test.TestTwo" name="passes"/>
+    <testcase classname="test.TestTwo" name="errors"><error message="test-message"/></testcase>
+  </testsuite>
+</testsuites>`)
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "target", "other-reports", "TEST-test.xml"), "invalid")
+
+			g.Expect(runner.NewTestResults(f.Build, []string{"target/surefire-reports", "target/failsafe-reports"}, nil).
+				Summary(f.Build.Application)).To(gomega.Equal(runner.TestSummary{
+				Failed:  []string{"test.TestOne.fails", "test.TestTwo.errors"},
+				Passed:  2,
+				Skipped: 1,
+			}))
+		})
+
+		it("fails if tests failed", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "test-results", "test", "TEST-test.xml"), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="test.TestOne">
+  <testcase classname="test.TestOne" name="fails"><failure message="test-message"/></testcase>
+</testsuite>`)
+
+			g.Expect(runner.NewTestResults(f.Build, []string{"build/test-results"}, nil).Verify(f.Build.Application)).
+				To(gomega.Equal(runner.TestFailureError{Failures: []string{"test.TestOne.fails"}}))
+		})
+
+		it("passes if all tests passed", func() {
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "build", "test-results", "test", "TEST-test.xml"), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="test.TestOne">
+  <testcase classname="test.TestOne" name="passes"/>
+</testsuite>`)
+
+			g.Expect(runner.NewTestResults(f.Build, []string{"build/test-results"}, nil).Verify(f.Build.Application)).
+				To(gomega.Succeed())
+		})
+
+		it("persists reports", func() {
+			test.TouchFile(t, f.Build.Application.Root, "build", "test-results", "test", "TEST-test.xml")
+			test.TouchFile(t, f.Build.Application.Root, "test-module", "build", "reports", "tests", "index.html")
+			test.TouchFile(t, f.Build.Application.Root, "test-module", "build", "jacoco", "test.exec")
+			test.TouchFile(t, f.Build.Application.Root, "build", "libs", "test.jar")
+
+			g.Expect(runner.NewTestResults(f.Build, nil, []string{"build/test-results", "build/reports", "build/jacoco"}).
+				Persist(f.Build.Application)).To(gomega.Succeed())
+
+			layer := f.Build.Layers.Layer("build-system-reports")
+			g.Expect(layer).To(test.HaveLayerMetadata(true, false, false))
+			g.Expect(layer.MetadataMatches(runner.TestReports{Reports: []string{
+				"build/test-results",
+				"test-module/build/jacoco",
+				"test-module/build/reports",
+			}})).To(gomega.BeTrue())
+			g.Expect(filepath.Join(layer.Root, "build", "test-results", "test", "TEST-test.xml")).To(gomega.BeARegularFile())
+			g.Expect(filepath.Join(layer.Root, "test-module", "build", "reports", "tests", "index.html")).To(gomega.BeARegularFile())
+			g.Expect(filepath.Join(layer.Root, "test-module", "build", "jacoco", "test.exec")).To(gomega.BeARegularFile())
+			g.Expect(filepath.Join(layer.Root, "build", "libs")).NotTo(gomega.BeAnExistingFile())
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
+)
+
+// CACertificatesBinding is the type of the service binding that contributes CA certificates.
+const CACertificatesBinding = "ca-certificates"
+
+// trustStorePassword is the password of the truststore, matching the default password of the JDK's cacerts.
+const trustStorePassword = "changeit"
+
+// TrustStore is a Configurer that creates an ephemeral truststore containing the JDK's CA certificates and those
+// contributed by the ca-certificates service binding.  The truststore is passed to the build system's JVM, and to the
+// JVMs it starts, with the javax.net.ssl.trustStore system property so that the JDK itself is never modified.
+type TrustStore struct {
+	// Certificates are the PEM encoded certificates, keyed by name, added to the truststore.
+	Certificates map[string]string
+
+	environment string
+	logger      logger.Logger
+	runner      runner.Runner
+}
+
+// Configure makes TrustStore satisfy the Configurer interface.
+func (t TrustStore) Configure(execution *Execution) error {
+	if len(t.Certificates) == 0 {
+		return nil
+	}
+
+	javaHome, ok := os.LookupEnv("JAVA_HOME")
+	if !ok {
+		return fmt.Errorf("$JAVA_HOME must be set to add %s to the truststore", CACertificatesBinding)
+	}
+
+	trustStore := filepath.Join(execution.Scratch, "truststore")
+	t.logger.Body("Adding %d CA certificates to truststore", len(t.Certificates))
+
+	cacerts, ok, err := t.cacerts(javaHome)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("unable to find cacerts in %s to add %s to the truststore", javaHome, CACertificatesBinding)
+	}
+
+	t.logger.Debug("Copying %s to %s", cacerts, trustStore)
+	if err := helper.CopyFile(cacerts, trustStore); err != nil {
+		return err
+	}
+
+	var names []string
+	for n := range t.Certificates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		rest := []byte(t.Certificates[n])
+
+		for i := 0; ; i++ {
+			var b *pem.Block
+			if b, rest = pem.Decode(rest); b == nil {
+				if i == 0 {
+					return fmt.Errorf("%s binding key %s does not contain a PEM encoded certificate",
+						CACertificatesBinding, n)
+				}
+				break
+			}
+
+			if b.Type != "CERTIFICATE" {
+				continue
+			}
+
+			alias := fmt.Sprintf("%s-%s-%d", CACertificatesBinding, n, i)
+			file := filepath.Join(execution.Scratch, alias+".pem")
+			if err := helper.WriteFile(file, 0644, "%s", pem.EncodeToMemory(b)); err != nil {
+				return err
+			}
+
+			t.logger.Debug("Importing %s as %s", n, alias)
+			if out, err := t.runner.RunWithOutput(filepath.Join(javaHome, "bin", "keytool"), execution.Scratch,
+				"-importcert", "-noprompt", "-trustcacerts", "-alias", alias, "-file", file,
+				"-keystore", trustStore, "-storepass", trustStorePassword); err != nil {
+
+				return fmt.Errorf("unable to import %s into truststore: %w\n%s", n, err, out)
+			}
+		}
+	}
+
+	execution.AppendEnvironment(t.environment,
+		fmt.Sprintf("-Djavax.net.ssl.trustStore=%s -Djavax.net.ssl.trustStorePassword=%s", trustStore, trustStorePassword))
+	execution.SystemProperties["javax.net.ssl.trustStore"] = trustStore
+	execution.SystemProperties["javax.net.ssl.trustStorePassword"] = trustStorePassword
+	return nil
+}
+
+// Digest makes TrustStore satisfy the Configurer interface.
+func (t TrustStore) Digest() (string, error) {
+	if len(t.Certificates) == 0 {
+		return "", nil
+	}
+
+	return digest(t.Certificates, t.environment)
+}
+
+func (TrustStore) cacerts(javaHome string) (string, bool, error) {
+	for _, c := range []string{
+		filepath.Join(javaHome, "lib", "security", "cacerts"),
+		filepath.Join(javaHome, "jre", "lib", "security", "cacerts"),
+	} {
+		if exists, err := helper.FileExists(c); err != nil {
+			return "", false, err
+		} else if exists {
+			return c, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// NewTrustStore creates a new TrustStore instance, populated from the ca-certificates service binding if one exists.
+// The truststore is passed to the JVM using the environment variable named by environment.
+func NewTrustStore(build build.Build, environment string) (TrustStore, error) {
+	t := TrustStore{
+		Certificates: make(map[string]string),
+		environment:  environment,
+		logger:       build.Logger,
+		runner:       build.Runner,
+	}
+
+	c, ok := build.Services.FindServiceCredentials(CACertificatesBinding)
+	if !ok {
+		return t, nil
+	}
+
+	for k, v := range c {
+		s, ok := v.(string)
+		if !ok {
+			return TrustStore{}, fmt.Errorf("%s binding key %s is not a string", CACertificatesBinding, k)
+		}
+
+		t.Certificates[k] = s
+	}
+
+	return t, nil
+}
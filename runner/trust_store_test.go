@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runner_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/build-system-cnb/runner"
+	"github.com/cloudfoundry/libcfbuildpack/v2/services"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestTrustStore(t *testing.T) {
+	spec.Run(t, "TrustStore", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			e        runner.Execution
+			f        *test.BuildFactory
+			javaHome string
+		)
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			e = runner.NewExecution(test.ScratchDir(t, "execution"))
+			javaHome = filepath.Join(f.Home, "jdk")
+		})
+
+		it("does not configure without binding", func() {
+			s, err := runner.NewTrustStore(f.Build, "TEST_OPTS")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+			g.Expect(e.Environment).To(gomega.BeEmpty())
+			g.Expect(f.Runner.Commands).To(gomega.BeEmpty())
+		})
+
+		it("imports certificates into copy of cacerts", func() {
+			defer test.ReplaceEnv(t, "JAVA_HOME", javaHome)()
+			defer test.ReplaceEnv(t, "TEST_OPTS", "-Xmx1G")()
+			test.WriteFile(t, filepath.Join(javaHome, "lib", "security", "cacerts"), "test-cacerts")
+
+			b, err := ioutil.ReadFile(filepath.Join("testdata", "test-certificate.pem"))
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			f.AddService("test-ca-certificates", services.Credentials{"test": string(b)}, runner.CACertificatesBinding)
+			f.Runner.Outputs = []string{""}
+
+			s, err := runner.NewTrustStore(f.Build, "TEST_OPTS")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(s.Configure(&e)).To(gomega.Succeed())
+
+			trustStore := filepath.Join(e.Scratch, "truststore")
+			g.Expect(trustStore).To(test.HaveContent("test-cacerts"))
+			g.Expect(filepath.Join(e.Scratch, "ca-certificates-test-0.pem")).To(test.HaveContent(string(b)))
+			g.Expect(f.Runner.Commands).To(gomega.Equal([]test.Command{
+				{
+					Bin: filepath.Join(javaHome, "bin", "keytool"),
+					Dir: e.Scratch,
+					Args: []string{"-importcert", "-noprompt", "-trustcacerts", "-alias", "ca-certificates-test-0",
+						"-file", filepath.Join(e.Scratch, "ca-certificates-test-0.pem"), "-keystore", trustStore,
+						"-storepass", "changeit"},
+				},
+			}))
+			g.Expect(e.Environment).To(gomega.HaveKeyWithValue("TEST_OPTS",
+				fmt.Sprintf("-Xmx1G -Djavax.net.ssl.trustStore=%s -Djavax.net.ssl.trustStorePassword=changeit", trustStore)))
+			g.Expect(e.SystemProperties).To(gomega.Equal(map[string]string{
+				"javax.net.ssl.trustStore":         trustStore,
+				"javax.net.ssl.trustStorePassword": "changeit",
+			}))
+		})
+
+		it("fails without cacerts", func() {
+			defer test.ReplaceEnv(t, "JAVA_HOME", javaHome)()
+
+			b, err := ioutil.ReadFile(filepath.Join("testdata", "test-certificate.pem"))
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			f.AddService("test-ca-certificates", services.Credentials{"test": string(b)}, runner.CACertificatesBinding)
+
+			s, err := runner.NewTrustStore(f.Build, "TEST_OPTS")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(s.Configure(&e)).To(gomega.MatchError(
+				fmt.Sprintf("unable to find cacerts in %s to add ca-certificates to the truststore", javaHome)))
+		})
+
+		it("fails with invalid certificate", func() {
+			defer test.ReplaceEnv(t, "JAVA_HOME", javaHome)()
+			test.WriteFile(t, filepath.Join(javaHome, "lib", "security", "cacerts"), "test-cacerts")
+			f.AddService("test-ca-certificates", services.Credentials{"test": "test-certificate"}, runner.CACertificatesBinding)
+
+			s, err := runner.NewTrustStore(f.Build, "TEST_OPTS")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(s.Configure(&e)).To(gomega.MatchError("ca-certificates binding key test does not contain a PEM encoded certificate"))
+		})
+	}, spec.Report(report.Terminal{}))
+}